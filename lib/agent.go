@@ -4,6 +4,8 @@ import (
 	"eco/lib/consumable"
 	"eco/lib/producer"
 	"fmt"
+	"math"
+	"sort"
 	"sync"
 )
 
@@ -15,21 +17,102 @@ type Agent struct {
 	Greed int
 
 	Producers      []producer.Producer
-	Consumables    []consumable.Consumable
+	Consumables    consumable.Lots
 	Inventory      map[string]Inventory
 	Demands        []consumable.Demand
 	LaborContracts []LaborContract
 
 	Market             *Market
 	LaborMarket        *LaborMarket
+	Ledger             *Ledger
 	TransactionChannel chan Transaction
 
+	// Government, when set, withholds
+	// income tax from the agent's wages.
+	Government *Government
+
+	// Bank, when set, holds the agent's
+	// deposit and lends to it.
+	Bank *Bank
+
+	// Capital, when set, is where the agent
+	// buys and sells producers.
+	Capital *CapitalMarket
+
+	// Owner, when set, is the agent that founded this
+	// one, and is paid what is left of it once wound up.
+	Owner *Agent
+
 	SeeksWage        bool
 	IsEmployed       bool
 	EmploymentSought bool
 
+	// ReservationWage is the least the agent
+	// will work a production cycle for.
+	ReservationWage float64
+
+	// Skills is how productive the agent is at each
+	// producer, by key, as a multiple of its Rate.
+	// It works a producer it has no skill at at one.
+	Skills map[string]float64
+
+	// Learning is how fast the agent's skills grow:
+	// every cycle it works adds Learning over its
+	// current skill to its skill at that producer.
+	Learning float64
+
+	// PriceRaise and PriceCut are how fast the agent learns
+	// its markups: the fraction a markup rises by when
+	// everything listed sold, and falls by when nothing did.
+	// Zero keeps markups at Greed.
+	PriceRaise float64
+	PriceCut   float64
+
+	// Reinvest is the share of its retained earnings the
+	// agent is willing to invest in more producers.
+	// Zero only ever replaces worn out producers.
+	Reinvest float64
+
 	Report Report
 
+	// Strategy makes the agent's decisions.
+	// A nil Strategy uses DefaultStrategy.
+	Strategy Strategy
+
+	// paid is the average price last paid
+	// for each good, by key.
+	paid map[string]float64
+
+	// escrow holds the cash reserved for
+	// each settlement still open, by escrow.
+	escrow map[int]float64
+
+	// reserved holds the cash set aside for
+	// the bid outstanding at each key.
+	reserved map[string]reservation
+	refs     int
+
+	// markup is what the agent asks over cost for each
+	// good it sells, in multiples of the good's value.
+	// sold is how many units of each it sold since the
+	// markups were last set, and unitCost what a unit of
+	// the latest batch sent to market cost.
+	markup   map[string]float64
+	sold     map[string]int
+	unitCost map[string]float64
+
+	// tick is the tick being acted out,
+	// stamped on everything produced.
+	tick int
+
+	// insolvent is how many ticks in a row the
+	// agent could not pay for a production cycle.
+	insolvent int
+
+	// unmaintained holds the producers whose
+	// maintenance went unpaid this tick.
+	unmaintained map[producer.Producer]bool
+
 	quit   chan bool
 	done   chan bool
 	rwLock sync.Mutex
@@ -41,7 +124,15 @@ func NewAgent(m *Market, l *LaborMarket) Agent {
 		Market:             m,
 		LaborMarket:        l,
 		TransactionChannel: make(chan Transaction),
-		Consumables:        []consumable.Consumable{},
+		Consumables:        consumable.Lots{},
+		Skills:             map[string]float64{},
+		paid:               map[string]float64{},
+		escrow:             map[int]float64{},
+		reserved:           map[string]reservation{},
+		markup:             map[string]float64{},
+		sold:               map[string]int{},
+		unitCost:           map[string]float64{},
+		unmaintained:       map[producer.Producer]bool{},
 		quit:               make(chan bool),
 		done:               make(chan bool),
 		rwLock:             sync.Mutex{},
@@ -53,40 +144,364 @@ func (a *Agent) Start() {
 }
 
 func (a *Agent) Quit() {
-	<-a.done
 	a.quit <- true
 }
 
 func (a *Agent) SendToMarket() {
-	for k, inv := range a.Inventory {
-		price := inv.Cost / float64(len(inv.Goods))
-		price += float64(a.Greed) * inv.Consumable.Value()
+	// Walk the keys in order so that listings
+	// reach the market in a fixed sequence
+	keys := []string{}
+	for k := range a.Inventory {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
-		a.Report.SentToMarket += len(inv.Goods)
+	for _, k := range keys {
+		inv := a.Inventory[k]
+		a.rwLock.Lock()
+		a.unitCost[k] = inv.Cost / float64(inv.Goods.Count())
+		a.rwLock.Unlock()
+		price := a.strategy().Price(a, inv)
 
-		a.Market.Push(k, Inventory{
-			Originator:         a.Name,
-			Goods:              inv.Goods,
+		a.Report.SentToMarket += inv.Goods.Count()
+
+		a.Market.Submit(Order{
+			From:               a.Name,
+			Side:               Ask,
+			Quantity:           inv.Goods.Count(),
 			Price:              price,
+			Goods:              inv.Goods,
 			Consumable:         inv.Consumable,
-			TransactionChannel: a.TransactionChannel,
+			FulfillmentChannel: a.TransactionChannel,
 		})
 		delete(a.Inventory, k)
-		fmtDebug("%s sent %d %s to market.\n", a.Name, len(inv.Goods), k)
+		fmtDebug("%s sent %d %s to market.\n", a.Name, inv.Goods.Count(), k)
+	}
+}
+
+// Finance moves cash between the agent and its deposit
+// until the deposit is what the strategy wants to save,
+// and borrows what the strategy asks for.
+func (a *Agent) Finance() {
+	if a.Bank == nil {
+		return
+	}
+
+	deposit := a.Bank.Statement(a.Name).Deposit
+	save := a.strategy().Save(a)
+	if save > deposit {
+		a.Bank.Deposit(a, save-deposit)
+	} else if save < deposit {
+		a.Bank.Withdraw(a, deposit-save)
+	}
+
+	if borrow := a.strategy().Borrow(a); borrow > 0 {
+		a.Bank.Lend(a, borrow)
+	}
+}
+
+// Solvent reports whether the agent could pay for a cycle
+// of one of its producers out of its cash, its deposit and
+// what the bank would lend it. Agents without producers
+// are always solvent.
+func (a *Agent) Solvent() bool {
+	if len(a.Producers) < 1 {
+		return true
+	}
+
+	funds := a.Available() + a.Bank.Statement(a.Name).Deposit + a.Bank.CreditLimit(a)
+	for _, p := range a.Producers {
+		if funds >= (p.Wage()+p.Cost())*float64(p.Rate()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Insolvent records whether the agent is Solvent this tick
+// and returns how many ticks in a row it has not been.
+func (a *Agent) Insolvent() int {
+	solvent := a.Solvent()
+
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	a.insolvent++
+	if solvent {
+		a.insolvent = 0
+	}
+	return a.insolvent
+}
+
+// Liquidate winds the agent up. Every worker is let go and
+// every bid withdrawn, its unsold goods are put up at their
+// value, and the bank takes what it is owed and writes off
+// the rest. It returns the producers, which the agent no
+// longer owns. What they and its goods still fetch is left
+// for whoever winds it up to pay out.
+func (a *Agent) Liquidate(reason string) []producer.Producer {
+	for len(a.LaborContracts) > 0 {
+		a.Fire(a.LaborContracts[0], reason)
+	}
+	a.Market.CancelBids(a.Name)
+
+	keys := []string{}
+	for key := range a.Inventory {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		inv := a.Inventory[key]
+		a.Market.Submit(Order{
+			From:               a.Name,
+			Side:               Ask,
+			Quantity:           inv.Goods.Count(),
+			Price:              inv.Consumable.Value(),
+			Goods:              inv.Goods,
+			Consumable:         inv.Consumable,
+			FulfillmentChannel: a.TransactionChannel,
+		})
+		delete(a.Inventory, key)
+	}
+	for _, p := range a.Producers {
+		value := p.Type().Value()
+		a.Market.Reprice(a.Name, p.Type().Key(), func(Inventory) float64 {
+			return value
+		})
+	}
+
+	a.Bank.Close(a)
+
+	producers := a.Producers
+	a.Producers = nil
+	fmtDebug("%s has been liquidated, %s.\n", a.Name, reason)
+	return producers
+}
+
+// Invest sells the producers the strategy wants rid of on
+// the capital market, and buys the ones it wants there.
+func (a *Agent) Invest() {
+	if a.Capital == nil {
+		return
+	}
+
+	for _, p := range a.strategy().Divest(a) {
+		for i := range a.Producers {
+			if a.Producers[i] == p {
+				a.Producers = append(a.Producers[:i], a.Producers[i+1:]...)
+				a.Capital.List(a, p)
+				break
+			}
+		}
+	}
+
+	for _, key := range a.strategy().Invest(a) {
+		p := a.Capital.Buy(a, key)
+		if p == nil {
+			continue
+		}
+		a.Producers = append(a.Producers, p)
+		a.Report.Invested += p.Value()
 	}
 }
 
+// Maintain pays for the upkeep of every producer, and wears
+// each by a tick. A producer whose maintenance cannot be paid
+// stands idle for the tick. Worn out producers are scrapped
+// as long as the agent has another to run.
+func (a *Agent) Maintain() {
+	a.unmaintained = map[producer.Producer]bool{}
+
+	for _, p := range a.Producers {
+		if cost := p.Maintenance(); cost > 0 {
+			memo := fmt.Sprintf("Maintenance of %s", p.Key())
+			if !deliver(a.TransactionChannel, Transaction{
+				CashOut: cost,
+				Memo:    memo,
+				From:    p.Key(),
+			}) {
+				a.unmaintained[p] = true
+				fmtDebug("%s could not maintain its %s.\n", a.Name, p.Key())
+			} else {
+				a.Ledger.Transfer(a.Name, ProductionAccount(p.Key()), cost, memo)
+				a.Report.Maintenance += cost
+			}
+		}
+
+		value := p.Value()
+		p.Depreciate()
+		a.Report.Depreciation += value - p.Value()
+	}
+
+	kept := []producer.Producer{}
+	for _, p := range a.Producers {
+		if !worn(p) {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) < 1 {
+		return
+	}
+	for _, p := range a.Producers {
+		if worn(p) {
+			a.Capital.Scrap(a, p)
+		}
+	}
+	a.Producers = kept
+}
+
+// Collateral returns what the agent could pledge to the
+// bank: its unsold goods at cost and its producers.
+func (a *Agent) Collateral() float64 {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	collateral := 0.0
+	for _, p := range a.Producers {
+		collateral += p.Value()
+	}
+	for _, inv := range a.Inventory {
+		collateral += inv.Cost
+	}
+	return collateral
+}
+
+// Markup returns what the agent asks over cost for key,
+// in multiples of the good's value. It starts at Greed.
+func (a *Agent) Markup(key string) float64 {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	if markup, ok := a.markup[key]; ok {
+		return markup
+	}
+	return float64(a.Greed)
+}
+
+// Reprice has the strategy set a new markup for every good
+// the agent produces, from what sold since the last tick
+// and what is still listed, and reprices the listings
+// still resting on the market to match.
+func (a *Agent) Reprice() {
+	for _, p := range a.Producers {
+		c := p.Type()
+		key := c.Key()
+
+		a.rwLock.Lock()
+		sold := a.sold[key]
+		delete(a.sold, key)
+		a.rwLock.Unlock()
+
+		unsold := a.Market.ListedOf(a.Name, key)
+		previous := a.Markup(key)
+		markup := a.strategy().Markup(a, key, sold, unsold)
+
+		a.rwLock.Lock()
+		a.markup[key] = markup
+		unitCost, ok := a.unitCost[key]
+		a.rwLock.Unlock()
+
+		if markup == previous || unsold < 1 || !ok {
+			continue
+		}
+		fmtDebug("%s moved its markup on %s from %.2f to %.2f. (%d sold, %d unsold)\n", a.Name, key, previous, markup, sold, unsold)
+
+		price := unitCost + markup*c.Value()
+		a.Market.Reprice(a.Name, key, func(Inventory) float64 {
+			return price
+		})
+	}
+}
+
+// Skill returns how productive the agent is at the
+// producer key, as a multiple of its Rate.
+func (a *Agent) Skill(key string) float64 {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	if skill, ok := a.Skills[key]; ok {
+		return skill
+	}
+	return 1
+}
+
+// Learn adds the experience of a cycle worked at
+// the producer key to the agent's skill at it.
+func (a *Agent) Learn(key string) {
+	if a.Learning <= 0 {
+		return
+	}
+	skill := a.Skill(key)
+
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	if a.Skills == nil {
+		a.Skills = map[string]float64{}
+	}
+	a.Skills[key] = skill + a.Learning/skill
+}
+
+// SetTick sets the tick the agent is acting in.
+func (a *Agent) SetTick(tick int) {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	a.tick = tick
+}
+
+// Spoil throws out every held unit, listed or not,
+// that has expired.
+func (a *Agent) Spoil() {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	fresh, spoiled := a.Consumables.Expire(a.tick)
+	a.Consumables = fresh
+
+	for key, inv := range a.Inventory {
+		goods, s := inv.Goods.Expire(a.tick)
+		spoiled += s
+		if len(goods) == 0 {
+			delete(a.Inventory, key)
+			continue
+		}
+		inv.Goods = goods
+		a.Inventory[key] = inv
+	}
+
+	a.Report.Spoiled += spoiled
+	if spoiled > 0 {
+		fmtDebug("%s threw out %d spoiled goods.\n", a.Name, spoiled)
+	}
+}
+
+func (a *Agent) strategy() Strategy {
+	if a.Strategy == nil {
+		return DefaultStrategy{}
+	}
+	return a.Strategy
+}
+
 func (a *Agent) Actions() []string {
 	// lock and get an image of our cash
+	a.Finance()
 	a.rwLock.Lock()
 	cash := a.Cash
 	isEmployed := a.IsEmployed
 	a.rwLock.Unlock()
+	a.Spoil()
+	a.Consume()
 	a.FillDemands(cash)
 
 	if !a.SeeksWage {
+		a.Invest()
+		a.Maintain()
 		a.SeekLabor()
+		a.Layoff()
 		a.Produce(cash)
+		a.Reprice()
 		a.SendToMarket()
 	}
 
@@ -94,6 +509,7 @@ func (a *Agent) Actions() []string {
 		fmtDebug("%s seeks employment.\n", a.Name)
 		a.LaborMarket.Append(LaborContract{
 			Agent: a,
+			Wage:  a.ReservationWage,
 		})
 		a.EmploymentSought = true
 	}
@@ -101,98 +517,361 @@ func (a *Agent) Actions() []string {
 	return a.ReportRecord()
 }
 
+// Consume eats Scale units of each demanded consumable from
+// the agent's holdings, recording any shortfall as unmet demand.
+func (a *Agent) Consume() {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	for _, d := range a.Demands {
+		key := d.Consumable.Key()
+		want := d.Consumable.Scale()
+
+		// Oldest first
+		taken, kept := a.Consumables.TakeOf(key, want)
+		eaten := taken.Count()
+		a.Consumables = kept
+
+		a.Report.Consumed += eaten
+		a.Report.UnmetDemand += want - eaten
+		if eaten < want {
+			fmtDebug("%s wanted %d %s but only had %d.\n", a.Name, want, key, eaten)
+		}
+	}
+}
+
+// Balance returns the cash the agent holds.
+func (a *Agent) Balance() float64 {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	return a.Cash
+}
+
+// Holding returns how many units of key the agent holds.
+func (a *Agent) Holding(key string) int {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	return a.Consumables.CountOf(key)
+}
+
+// Need returns how many more units the agent wants to satisfy d.
+func (a *Agent) Need(d consumable.Demand) int {
+	need := d.Quantity - a.Holding(d.Consumable.Key())
+	if need < 0 {
+		return 0
+	}
+	return need
+}
+
+// Paid returns the average price the agent last paid for key.
+func (a *Agent) Paid(key string) float64 {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	return a.paid[key]
+}
+
+// InputsNeeded returns how many units of each good, by key,
+// the agent lacks to work every LaborContract on the
+// producer the strategy assigns it to this tick.
+func (a *Agent) InputsNeeded() map[string]int {
+	needed := map[string]int{}
+	staffed := a.strategy().Assign(a, a.LaborContracts)
+	for i, p := range a.Producers {
+		for _, l := range staffed[i] {
+			n := units(p, l.Agent)
+			for _, in := range p.Inputs() {
+				needed[in.Consumable.Key()] += inputQuantity(p, in, n)
+			}
+		}
+	}
+	for key, q := range needed {
+		q -= a.Holding(key)
+		if q < 0 {
+			q = 0
+		}
+		needed[key] = q
+	}
+	return needed
+}
+
+// units returns how many units a cycle of p worked by
+// worker makes: its Rate scaled by the worker's skill.
+func units(p producer.Producer, worker *Agent) int {
+	skill := worker.Skill(p.Key())
+	if skill == 1 {
+		return p.Rate()
+	}
+	return int(math.Max(1, math.Round(float64(p.Rate())*skill)))
+}
+
+// inputQuantity returns how much of in a cycle of p making
+// n units uses up. Materials are used up per unit.
+func inputQuantity(p producer.Producer, in producer.Input, n int) int {
+	if n == p.Rate() {
+		return in.Quantity
+	}
+	return int(math.Ceil(float64(in.Quantity*n) / float64(p.Rate())))
+}
+
+// takeInputs removes the inputs of a cycle of p making n
+// units from the agent's holdings and returns them with what
+// they cost. It takes nothing unless every input is held.
+func (a *Agent) takeInputs(p producer.Producer, n int) (consumable.Lots, float64, bool) {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	used := consumable.Lots{}
+	kept := a.Consumables
+	cost := 0.0
+	for _, in := range p.Inputs() {
+		key := in.Consumable.Key()
+		q := inputQuantity(p, in, n)
+		taken, rest := kept.TakeOf(key, q)
+		if taken.Count() < q {
+			return nil, 0, false
+		}
+		used = append(used, taken...)
+		kept = rest
+		cost += float64(q) * a.paid[key]
+	}
+	a.Consumables = kept
+	return used, cost, true
+}
+
+// returnInputs puts back inputs taken for a
+// cycle that did not go ahead.
+func (a *Agent) returnInputs(inputs consumable.Lots) {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	a.Consumables = append(inputs, a.Consumables...)
+}
+
+// Available returns the cash the agent holds that
+// no outstanding bid has a claim on.
+func (a *Agent) Available() float64 {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	return a.Cash - a.reservedCash()
+}
+
+// reservedCash returns the cash set aside for outstanding
+// bids. The caller must hold a.rwLock.
+func (a *Agent) reservedCash() float64 {
+	total := 0.0
+	for _, r := range a.reserved {
+		total += r.Cash
+	}
+	return total
+}
+
+// release frees what is left of the reservation for the
+// order ref at key. The caller must hold a.rwLock.
+func (a *Agent) release(key string, ref int) {
+	if r, ok := a.reserved[key]; ok && r.Ref == ref {
+		delete(a.reserved, key)
+	}
+}
+
+// FillDemands places the strategy's bids, sharing out the
+// cash no other bid has a claim on by priority, and
+// reserving each bid's share until it is done with.
 func (a *Agent) FillDemands(cash float64) {
-	for i := range a.Demands {
-		d := a.Demands[i]
-		a.Market.OrderChannel <- Order{
-			From:               a.Name,
-			Quantity:           d.Quantity,
-			Consumable:         d.Consumable,
-			Cash:               cash,
-			FulfillmentChannel: a.TransactionChannel,
+	orders := a.strategy().Bids(a, cash)
+
+	// Each bid replaces whatever rests at its key,
+	// freeing its reservation to be shared out again
+	a.rwLock.Lock()
+	for _, o := range orders {
+		delete(a.reserved, o.Consumable.Key())
+	}
+	shares := Allocate(a.Cash-a.reservedCash(), orders)
+	for i := range orders {
+		a.refs++
+		orders[i].Ref = a.refs
+		orders[i].Cash = shares[i]
+		if shares[i] > 0 {
+			a.reserved[orders[i].Consumable.Key()] = reservation{Ref: a.refs, Cash: shares[i]}
 		}
 	}
+	a.rwLock.Unlock()
+
+	for _, o := range orders {
+		o.From = a.Name
+		o.Side = Bid
+		o.FulfillmentChannel = a.TransactionChannel
+		a.Market.Submit(o)
+	}
 }
 
+// SeekLabor posts the job offers the strategy makes for
+// the labor on offer. They are settled by LaborMarket.Match.
 func (a *Agent) SeekLabor() {
-	lc := a.LaborMarket.Shift()
-	l, hadLabor := <-lc
-	if !hadLabor {
-		fmtDebug("%s sought labor but there was none.\n", a.Name)
-		return
+	labor := []LaborContract{}
+	for l := range a.LaborMarket.Read() {
+		labor = append(labor, l)
+	}
+
+	for _, o := range a.strategy().Hire(a, labor) {
+		o.Employer = a
+		a.LaborMarket.Offer(o)
+		fmtDebug("%s offers a wage of %.2f.\n", a.Name, o.Wage)
+	}
+}
+
+// Layoff lets go of the workers the strategy no longer wants.
+func (a *Agent) Layoff() {
+	for _, l := range a.strategy().Layoffs(a) {
+		a.Fire(l, "business is bad")
+	}
+}
+
+// Fire ends the worker's contract and puts them
+// back on the LaborMarket.
+func (a *Agent) Fire(l LaborContract, reason string) {
+	for i := range a.LaborContracts {
+		if a.LaborContracts[i].Agent == l.Agent {
+			a.LaborContracts = append(a.LaborContracts[:i], a.LaborContracts[i+1:]...)
+			break
+		}
 	}
 
+	a.Report.Fired++
+	f := false
+	deliver(l.Agent.TransactionChannel, Transaction{
+		Employment: &f,
+		Memo:       fmt.Sprintf("%s has let %s go, %s.", a.Name, l.Agent.Name, reason),
+	})
+
+	a.LaborMarket.Append(LaborContract{
+		Agent: l.Agent,
+		Wage:  l.Agent.ReservationWage,
+	})
+}
+
+// Hire takes on the worker under l.
+func (a *Agent) Hire(l LaborContract) {
 	a.LaborContracts = append(a.LaborContracts, l)
 	a.Report.Hired++
 	t := true
-	l.Agent.TransactionChannel <- Transaction{
+	deliver(l.Agent.TransactionChannel, Transaction{
 		Employment: &t,
-		Memo:       fmt.Sprintf("%s has hired %s.", a.Name, l.Agent.Name),
-	}
+		Memo:       fmt.Sprintf("%s has hired %s at %.2f.", a.Name, l.Agent.Name, l.Wage),
+	})
 }
 
+// Produce works each producer with the LaborContracts the
+// strategy assigns it, one cycle per contract. A cycle yields
+// the producer's Rate scaled by the worker's skill at it, and
+// adds to that skill. Workers the agent cannot pay for their
+// cycle are let go.
 func (a *Agent) Produce(cash float64) {
 	if len(a.LaborContracts) < 1 {
 		fmtDebug("%s has no labor.\n", a.Name)
 		return
 	}
 
+	unpaid := map[*Agent]bool{}
+	defer func() {
+		for _, l := range append([]LaborContract{}, a.LaborContracts...) {
+			if unpaid[l.Agent] {
+				a.Fire(l, "they could not be paid")
+			}
+		}
+	}()
+
+	staffed := a.strategy().Assign(a, a.LaborContracts)
 	for i := range a.Producers {
 		p := a.Producers[i]
-		fmtDebug("%s will attempt %d production cycles with %s. %.2f\n", a.Name, len(a.LaborContracts), p.Key(), cash)
+		contracts := staffed[i]
+		if len(contracts) < 1 || a.unmaintained[p] {
+			continue
+		}
+		fmtDebug("%s will attempt %d production cycles with %s. %.2f\n", a.Name, len(contracts), p.Key(), cash)
 
 		estimate := p.Estimate()
 		if estimate > cash {
 			// We can't produce one cylce,
 			// let alone many
 			fmtDebug("%s cannot afford any production cylces.\n", a.Name)
+			for _, l := range contracts {
+				unpaid[l.Agent] = true
+			}
 			continue
 		}
 
-		rate := p.Rate()
 		productKey := p.Type().Key()
 
 		totalProduced := 0
 		totalWages := 0.0
 		totalCost := 0.0
 
-		// Just use up all our labor contracts on the first producer, for now
-		for j := range a.LaborContracts {
-			cost, wages, products := p.Produce()
+		for _, l := range contracts {
+			if unpaid[l.Agent] {
+				continue
+			}
+
+			// Skill scales the units a cycle makes, and
+			// with them the inputs and costs per unit
+			worker := l.Agent
+			n := units(p, worker)
+			inputs, inputCost, ok := a.takeInputs(p, n)
+			if !ok {
+				// A less skilled worker may need fewer
+				fmtDebug("%s does not hold the inputs for %s.\n", a.Name, p.Key())
+				continue
+			}
+
+			cost, _, lot := p.Produce()
+			wages := l.Wage
+			lot.Consumable.SetProduced(a.tick)
+			if n != lot.Quantity {
+				cost *= float64(n) / float64(lot.Quantity)
+				lot.Quantity = n
+			}
+			rate := lot.Quantity
 
 			if wages+cost > cash {
-				// TODO: What should happen in this situation
 				fmtDebug("%s could not afford production cost %.2f (%.2f + %.2f) / %.2f.\n", a.Name, wages+cost, wages, cost, cash)
+				unpaid[l.Agent] = true
+				a.returnInputs(inputs)
 				continue
 			}
 
 			// Deduct the costs
-			t := Transaction{
-				CashOut:          wages + cost,
-				Memo:             fmt.Sprintf("Cost to produce %d %v", rate, productKey),
-				From:             p.Key(),
-				AcceptChannel:    make(chan bool),
-				ResponseRequired: true,
-			}
-			go func() { a.TransactionChannel <- t }()
-			accepted := <-t.AcceptChannel
+			memo := fmt.Sprintf("Cost to produce %d %v", rate, productKey)
+			accepted := deliver(a.TransactionChannel, Transaction{
+				CashOut: wages + cost,
+				Memo:    memo,
+				From:    p.Key(),
+			})
 			if !accepted {
 				// can't pay wages
-				// TODO: worker should be made aware somehow
+				unpaid[l.Agent] = true
+				a.returnInputs(inputs)
 				continue
 			}
+			a.Ledger.Transfer(a.Name, ProductionAccount(p.Key()), cost, memo)
 
-			totalProduced += len(products)
+			totalProduced += lot.Quantity
 			totalWages += wages
 			totalCost += wages + cost
 
 			a.Report.ProductCylces++
 			a.Report.Production += rate
+			a.Report.InputsUsed += inputs.Count()
 
 			a.Report.WagesPaid += wages
 
 			// Pay the worker
-			a.LaborContracts[j].Agent.ReceiveCash(wages, fmt.Sprintf("Wages for producing %d %v", rate, productKey), a.Name)
+			memo = fmt.Sprintf("Wages for producing %d %v", rate, productKey)
+			a.Ledger.Transfer(a.Name, worker.Name, wages, memo)
+			worker.ReceiveCash(wages, memo, a.Name)
+			worker.Learn(p.Key())
 
 			inventory, ok := a.Inventory[productKey]
 			if !ok {
@@ -203,8 +882,8 @@ func (a *Agent) Produce(cash float64) {
 				panic("nil trans inv")
 			}
 
-			inventory.Cost += wages + cost
-			inventory.Goods = append(inventory.Goods, products...)
+			inventory.Cost += wages + cost + inputCost
+			inventory.Goods = inventory.Goods.Add(lot)
 
 			a.Inventory[productKey] = inventory
 		}
@@ -214,20 +893,25 @@ func (a *Agent) Produce(cash float64) {
 	}
 }
 
-func (a *Agent) SendGoods(goods []consumable.Consumable, memo string, from string) {
-	a.TransactionChannel <- Transaction{
+func (a *Agent) SendGoods(goods consumable.Lots, memo string, from string) {
+	deliver(a.TransactionChannel, Transaction{
 		ConsumablesIn: goods,
 		Memo:          memo,
 		From:          from,
-	}
+	})
 }
 
+// ReceiveCash pays the agent wages, less
+// the income tax the Government withholds.
 func (a *Agent) ReceiveCash(amount float64, memo string, from string) {
-	a.TransactionChannel <- Transaction{
-		CashIn: amount,
+	tax := a.Government.Due(TaxIncome, amount)
+	deliver(a.TransactionChannel, Transaction{
+		CashIn: amount - tax,
+		Tax:    tax,
 		Memo:   memo,
 		From:   from,
-	}
+	})
+	a.Government.Collect(TaxIncome, a.Name, tax, fmt.Sprintf("Income tax on %.2f", amount))
 }
 
 func (a *Agent) ProcessTransactions() {
@@ -244,6 +928,80 @@ func (a *Agent) ProcessTransactions() {
 					}
 				}()
 
+				switch t.Phase {
+				case PhaseReserve:
+					// The order may draw on its own
+					// reservation and on free cash
+					r := a.reserved[t.ConsumableKey]
+					if r.Ref != t.OrderRef {
+						r = reservation{}
+					}
+					if t.CashOut > a.Cash-a.reservedCash()+r.Cash {
+						transactionAccepted = false
+						return
+					}
+					a.Cash -= t.CashOut
+					a.escrow[t.Escrow] = t.CashOut
+					if r.Ref != 0 {
+						r.Cash = math.Max(0, r.Cash-t.CashOut)
+						a.reserved[t.ConsumableKey] = r
+					}
+					return
+
+				case PhasePrepare:
+					// Nothing to do but be there to be paid
+					return
+
+				case PhaseRollback:
+					if held, ok := a.escrow[t.Escrow]; ok {
+						a.Cash += held
+						delete(a.escrow, t.Escrow)
+					}
+					switch {
+					case len(t.ConsumablesOut) > 0:
+						// A sale we were already paid for
+						a.Cash -= t.CashOut
+						a.Report.Revenue -= t.CashOut
+						a.Report.TaxesPaid -= t.Tax
+						a.sold[t.ConsumableKey] -= t.ConsumablesOut.Count()
+					case t.CashOut > 0:
+						// The market drops a bid that fails
+						a.release(t.ConsumableKey, t.OrderRef)
+					}
+					a.Report.FailedSettlements++
+					fmtDebug("%d: %s's trade of %s with %s fell through: %s\n", t.OrderIndex, a.Name, t.ConsumableKey, t.From, t.Failure)
+					return
+
+				case PhaseCommit:
+					if len(t.ConsumablesIn) > 0 {
+						paid := a.escrow[t.Escrow]
+						delete(a.escrow, t.Escrow)
+						a.Consumables = a.Consumables.Add(t.ConsumablesIn...)
+						fmtDebug("%d: %s paid %.2f to %s for %d %s\n", t.OrderIndex, a.Name, paid, t.From, t.ConsumablesIn.Count(), t.ConsumableKey)
+						return
+					}
+				}
+
+				if t.Fill != nil {
+					if t.Fill.Resting == 0 {
+						a.release(t.Fill.Key, t.Fill.OrderRef)
+					}
+					if t.Fill.Quantity == 0 {
+						return
+					}
+					a.Report.Purchased += t.Fill.Quantity
+					a.Report.PurchaseCost += t.Fill.Cost
+					a.paid[t.Fill.Key] = t.Fill.AveragePrice()
+					fmtDebug("%d: %s bought %d %s from %d sellers at an average of %.2f\n", t.OrderIndex, a.Name, t.Fill.Quantity, t.Fill.Key, len(t.Fill.Sellers), t.Fill.AveragePrice())
+					return
+				}
+
+				if t.Spoiled > 0 {
+					a.Report.Spoiled += t.Spoiled
+					fmtDebug("%s: %s\n", a.Name, t.Memo)
+					return
+				}
+
 				if t.Employment != nil {
 					a.IsEmployed = *t.Employment
 					fmtDebug("(Employment Change): %s\n", t.Memo)
@@ -259,13 +1017,24 @@ func (a *Agent) ProcessTransactions() {
 
 				if t.CashIn > 0.0 {
 					a.Cash += t.CashIn
-					a.Report.Revenue += t.CashIn
+					switch {
+					case t.Capital:
+						a.Report.Divested += t.CashIn
+					case t.From == AccountTreasury:
+						a.Report.Transfers += t.CashIn
+					case t.From == AccountBank:
+						// Loans and withdrawals are not earned
+					default:
+						a.Report.Revenue += t.CashIn
+						a.sold[t.ConsumableKey] += t.ConsumablesOut.Count()
+					}
 					prefix = "Received"
 					qStr = fmt.Sprintf("%.2f", t.CashIn)
 				}
 
 				if t.CashOut > 0.0 {
-					if t.CashOut > a.Cash {
+					// Cash set aside for bids cannot be spent twice
+					if t.CashOut > a.Cash-a.reservedCash() {
 						//panic(fmt.Sprintf("%.2f / %.2f %d %s", t.CashOut, a.Cash, t.OrderIndex, a.Name))
 						transactionAccepted = false
 						return
@@ -277,12 +1046,14 @@ func (a *Agent) ProcessTransactions() {
 					prefix = fmt.Sprintf("%s paid", a.Name)
 				}
 
+				a.Report.TaxesPaid += t.Tax
+
 				suffix := ""
 				if len(t.ConsumablesIn) > 0 {
 					prefix = fmt.Sprintf("%s paid %.2f to %s for", a.Name, t.CashOut, t.From)
-					qStr = fmt.Sprintf("%d %s", len(t.ConsumablesIn), t.ConsumableKey)
+					qStr = fmt.Sprintf("%d %s", t.ConsumablesIn.Count(), t.ConsumableKey)
 					suffix = ""
-					a.Consumables = append(a.Consumables, t.ConsumablesIn...)
+					a.Consumables = a.Consumables.Add(t.ConsumablesIn...)
 				}
 
 				if t.Memo != "" {
@@ -308,9 +1079,43 @@ func (a *Agent) ReportRecord() []string {
 		a.Name,
 		fmt.Sprintf("%d", a.Greed),
 		fmt.Sprintf("%.2f", a.Cash),
-		fmt.Sprintf("%d", len(a.Consumables)),
+		fmt.Sprintf("%d", a.Consumables.Count()),
 		fmt.Sprintf("%d", a.Report.SentToMarket),
 		fmt.Sprintf("%d", a.Report.Production),
 		fmt.Sprintf("%.2f", a.Report.Revenue),
 	}
 }
+
+// Record returns the agent's state as an AgentRecord for tick.
+func (a *Agent) Record(tick int) AgentRecord {
+	a.rwLock.Lock()
+	defer a.rwLock.Unlock()
+
+	report := a.Report
+	report.Consumables = a.Consumables.Count()
+	report.Employees = len(a.LaborContracts)
+	report.Producers = len(a.Producers)
+	report.Skill = 1
+	if len(a.Skills) > 0 {
+		report.Skill = 0
+	}
+	for _, skill := range a.Skills {
+		report.Skill = math.Max(report.Skill, skill)
+	}
+
+	statement := a.Bank.Statement(a.Name)
+	report.Deposit = statement.Deposit
+	report.Debt = statement.Debt
+	report.InterestEarned = statement.InterestEarned
+	report.InterestPaid = statement.InterestPaid
+	report.Defaults = statement.Defaults
+
+	return AgentRecord{
+		Tick:       tick,
+		Name:       a.Name,
+		Cash:       a.Cash,
+		SeeksWage:  a.SeeksWage,
+		IsEmployed: a.IsEmployed,
+		Report:     report,
+	}
+}
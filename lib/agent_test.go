@@ -0,0 +1,207 @@
+package lib
+
+import (
+	"eco/lib/consumable"
+	"eco/lib/producer"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConsume(t *testing.T) {
+	m := NewMarket()
+	l := NewLaborMarket()
+	a := NewAgent(&m, &l)
+	a.Demands = []consumable.Demand{{Consumable: consumable.NewApple(), Quantity: 10}}
+	a.Consumables = apples(7)
+
+	a.Consume()
+	assert.Equal(t, 2, a.Holding(consumable.KeyApple))
+	assert.Equal(t, 8, a.Need(a.Demands[0]))
+	assert.Equal(t, 0, a.Report.UnmetDemand)
+
+	a.Consume()
+	assert.Equal(t, 0, a.Holding(consumable.KeyApple))
+	assert.Equal(t, 7, a.Report.Consumed)
+	assert.Equal(t, 3, a.Report.UnmetDemand)
+}
+
+func TestLayoff(t *testing.T) {
+	m := NewMarket()
+	l := NewLaborMarket()
+	a := NewAgent(&m, &l)
+	a.Name = "employer"
+	a.Cash = 100
+	a.Producers = []producer.Producer{producer.NewOrchard()}
+
+	workers := []*Agent{}
+	for i, wage := range []float64{50, 80, 30} {
+		w := NewAgent(&m, &l)
+		w.Name = fmt.Sprintf("worker%d", i)
+		w.ReservationWage = 20
+		w.IsEmployed = true
+		go w.Start()
+		defer w.Quit()
+		workers = append(workers, &w)
+		a.LaborContracts = append(a.LaborContracts, LaborContract{Agent: &w, Employer: &a, Wage: wage})
+	}
+
+	// A payroll of 190 on 100 cash lets the best paid go
+	a.Layoff()
+	assert.Len(t, a.LaborContracts, 2)
+	assert.Equal(t, 1, a.Report.Fired)
+	assert.False(t, workers[1].IsEmployed)
+	assert.True(t, workers[0].IsEmployed)
+
+	left := []LaborContract{}
+	for c := range l.Read() {
+		left = append(left, c)
+	}
+	if assert.Len(t, left, 1) {
+		assert.Equal(t, workers[1], left[0].Agent)
+		assert.Equal(t, 20.0, left[0].Wage)
+	}
+}
+
+func TestProduceWithInputs(t *testing.T) {
+	c, err := NewCatalog(CatalogFile{
+		Consumables: []consumable.Definition{
+			{Key: consumable.KeyApple, Value: .25, Scale: 5},
+			{Key: "pie", Value: 1, Scale: 1},
+		},
+		Producers: []producer.Definition{
+			{Key: "bakery", Output: "pie", Rate: 2, Cost: 1, Wage: 5, Inputs: map[string]int{consumable.KeyApple: 4}},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	bakery, _ := c.Producers.New("bakery")
+
+	m := NewMarket()
+	l := NewLaborMarket()
+	a := NewAgent(&m, &l)
+	a.Name = "baker"
+	a.Cash = 100
+	a.Producers = []producer.Producer{bakery}
+	a.Inventory = map[string]Inventory{}
+	a.Consumables = apples(5)
+	a.paid[consumable.KeyApple] = 2
+	go a.Start()
+	defer a.Quit()
+
+	w := NewAgent(&m, &l)
+	w.Name = "worker"
+	go w.Start()
+	defer w.Quit()
+	a.LaborContracts = []LaborContract{{Agent: &w, Employer: &a, Wage: 10}}
+
+	assert.Equal(t, map[string]int{consumable.KeyApple: 0}, a.InputsNeeded())
+
+	a.Produce(a.Balance())
+	assert.Equal(t, 1, a.Holding(consumable.KeyApple))
+	assert.Equal(t, map[string]int{consumable.KeyApple: 3}, a.InputsNeeded())
+	assert.Equal(t, 4, a.Report.InputsUsed)
+	if assert.Equal(t, 2, a.Inventory["pie"].Goods.Count()) {
+		// Wage, cost and the apples at what was paid for them
+		assert.Equal(t, 10+2+8.0, a.Inventory["pie"].Cost)
+	}
+
+	// Out of apples, the worker idles but is kept on
+	a.Produce(a.Balance())
+	assert.Equal(t, 2, a.Inventory["pie"].Goods.Count())
+	assert.Len(t, a.LaborContracts, 1)
+	assert.Equal(t, 0, a.Report.Fired)
+
+	// Half again as skilled, the worker makes three
+	// pies out of half again as many apples
+	w.Skills["bakery"] = 1.5
+	assert.Equal(t, map[string]int{consumable.KeyApple: 5}, a.InputsNeeded())
+	a.SendGoods(apples(5), "Bought apples", "market")
+	a.Produce(a.Balance())
+	assert.Equal(t, 0, a.Holding(consumable.KeyApple))
+	assert.Equal(t, 4+6, a.Report.InputsUsed)
+	if assert.Equal(t, 5, a.Inventory["pie"].Goods.Count()) {
+		assert.Equal(t, 20+10+3+12.0, a.Inventory["pie"].Cost)
+	}
+}
+
+func TestSkillScalesOutput(t *testing.T) {
+	m := NewMarket()
+	l := NewLaborMarket()
+	a := NewAgent(&m, &l)
+	a.Name = "employer"
+	a.Cash = 100
+	a.Producers = []producer.Producer{producer.NewOrchard()}
+	a.Inventory = map[string]Inventory{}
+	go a.Start()
+	defer a.Quit()
+
+	w := NewAgent(&m, &l)
+	w.Name = "worker"
+	w.Skills[producer.KeyOrchard] = 1.5
+	w.Learning = .5
+	go w.Start()
+	defer w.Quit()
+	a.LaborContracts = []LaborContract{{Agent: &w, Employer: &a, Wage: 10}}
+
+	// Half again the orchard's rate, with the
+	// production cost of every unit
+	a.Produce(a.Balance())
+	assert.Equal(t, 15, a.Report.Production)
+	if assert.Equal(t, 15, a.Inventory[consumable.KeyApple].Goods.Count()) {
+		assert.Equal(t, 10+15.0, a.Inventory[consumable.KeyApple].Cost)
+	}
+
+	// Experience counts for less the more skilled
+	assert.Equal(t, 1.5+.5/1.5, w.Skill(producer.KeyOrchard))
+	assert.Equal(t, 1.0, w.Skill("bakery"))
+	assert.Equal(t, 1.5+.5/1.5, w.Record(1).Report.Skill)
+}
+
+func TestMarkupLearnsFromSales(t *testing.T) {
+	m := NewMarket()
+	m.Synchronous = true
+	l := NewLaborMarket()
+	a := NewAgent(&m, &l)
+	a.Name = "orchard"
+	a.Greed = 4
+	a.PriceRaise = .5
+	a.PriceCut = .5
+	a.Producers = []producer.Producer{producer.NewOrchard()}
+	a.Inventory = map[string]Inventory{
+		consumable.KeyApple: {Cost: 10, Goods: apples(10), Consumable: consumable.NewApple()},
+	}
+	go a.Start()
+	defer a.Quit()
+
+	// A unit cost of 1 and a markup of 4 quarters
+	a.SendToMarket()
+	if inv := <-m.ReadLowest(consumable.KeyApple); assert.Equal(t, 10, inv.Goods.Count()) {
+		assert.Equal(t, 2.0, inv.Price)
+	}
+
+	// Nothing sold, so the markup is cut in half
+	a.Reprice()
+	assert.Equal(t, 2.0, a.Markup(consumable.KeyApple))
+	assert.Equal(t, 1.5, (<-m.ReadLowest(consumable.KeyApple)).Price)
+
+	m.PlaceOrder(Order{
+		From:               "buyer",
+		Side:               Bid,
+		Quantity:           10,
+		Price:              1.5,
+		Cash:               100,
+		Consumable:         consumable.NewApple(),
+		FulfillmentChannel: newTrader().channel,
+	})
+	assert.Equal(t, 0, m.ListedOf(a.Name, consumable.KeyApple))
+
+	// Everything sold, so it goes up again
+	a.Reprice()
+	assert.Equal(t, 3.0, a.Markup(consumable.KeyApple))
+
+	// Without a sale or a listing there is nothing to learn
+	a.Reprice()
+	assert.Equal(t, 3.0, a.Markup(consumable.KeyApple))
+}
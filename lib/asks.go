@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// listing is an Inventory resting on an askBook. Seq orders
+// listings at the same price by when they were first listed.
+type listing struct {
+	Inventory
+	seq int
+}
+
+// askHeap is a min-heap of listings by price, then by seq.
+type askHeap []listing
+
+func (h askHeap) Len() int { return len(h) }
+
+func (h askHeap) Less(i, j int) bool {
+	if h[i].Price != h[j].Price {
+		return h[i].Price < h[j].Price
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h askHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *askHeap) Push(x interface{}) {
+	*h = append(*h, x.(listing))
+}
+
+func (h *askHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	l := old[n-1]
+	*h = old[:n-1]
+	return l
+}
+
+// askBook holds the asks resting for one key, giving the
+// lowest in O(1) and listing or removing in O(log n).
+type askBook struct {
+	asks askHeap
+	seq  int
+}
+
+// Len returns how many listings are resting.
+func (b *askBook) Len() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.asks)
+}
+
+// List rests inv behind every listing at the same price.
+func (b *askBook) List(inv Inventory) {
+	b.seq++
+	heap.Push(&b.asks, listing{Inventory: inv, seq: b.seq})
+}
+
+// Lowest returns the lowest priced listing.
+func (b *askBook) Lowest() (listing, bool) {
+	if b.Len() < 1 {
+		return listing{}, false
+	}
+	return b.asks[0], true
+}
+
+// PopLowest removes and returns the lowest priced listing.
+func (b *askBook) PopLowest() (listing, bool) {
+	if b.Len() < 1 {
+		return listing{}, false
+	}
+	return heap.Pop(&b.asks).(listing), true
+}
+
+// Relist puts back a listing taken off the book, such as
+// what is left of a partial fill. It keeps its place among
+// the listings at its price.
+func (b *askBook) Relist(l listing) {
+	if len(l.Goods) == 0 {
+		return
+	}
+	heap.Push(&b.asks, l)
+}
+
+// Filter keeps only the listings keep returns true for,
+// after keep has had the chance to update them.
+func (b *askBook) Filter(keep func(*Inventory) bool) {
+	if b == nil {
+		return
+	}
+	kept := b.asks[:0]
+	for _, l := range b.asks {
+		if keep(&l.Inventory) {
+			kept = append(kept, l)
+		}
+	}
+	b.asks = kept
+	heap.Init(&b.asks)
+}
+
+// Remove takes every listing match returns true for off
+// the book, and returns them lowest price first.
+func (b *askBook) Remove(match func(Inventory) bool) []listing {
+	if b == nil {
+		return nil
+	}
+	removed := askHeap{}
+	kept := b.asks[:0]
+	for _, l := range b.asks {
+		if match(l.Inventory) {
+			removed = append(removed, l)
+			continue
+		}
+		kept = append(kept, l)
+	}
+	b.asks = kept
+	heap.Init(&b.asks)
+
+	sort.Sort(removed)
+	return removed
+}
+
+// Each calls f with every listing, in no particular order.
+func (b *askBook) Each(f func(Inventory)) {
+	if b == nil {
+		return
+	}
+	for _, l := range b.asks {
+		f(l.Inventory)
+	}
+}
+
+// Sorted returns a copy of the listings, lowest price first.
+func (b *askBook) Sorted() []Inventory {
+	if b == nil {
+		return []Inventory{}
+	}
+	asks := append(askHeap{}, b.asks...)
+	sort.Sort(asks)
+
+	inventories := make([]Inventory, len(asks))
+	for i, l := range asks {
+		inventories[i] = l.Inventory
+	}
+	return inventories
+}
@@ -0,0 +1,476 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// AccountBank holds the bank's reserves: its capital
+// and whatever of its deposits it has not lent out.
+const AccountBank = "bank"
+
+// BankPolicy sets the terms a Bank deals on.
+// Rates are charged and paid every tick.
+type BankPolicy struct {
+	// Capital is the cash the bank starts with.
+	Capital float64
+
+	DepositRate float64
+	LoanRate    float64
+
+	// Term is how many ticks a loan is repaid over.
+	Term int
+
+	// LoanToValue is the share of an agent's collateral,
+	// its unsold goods at cost and its producers at
+	// value, the bank lends against.
+	LoanToValue float64
+
+	// CreditPerRepayment is what the bank lends without
+	// collateral for every loan the agent has repaid and
+	// not defaulted on since.
+	CreditPerRepayment float64
+
+	// Grace is how many installments in a row a
+	// borrower may miss before it is in default.
+	Grace int
+
+	// ReserveRatio is the share of its deposits the
+	// bank keeps in reserve rather than lends.
+	ReserveRatio float64
+}
+
+// Loan is cash an agent owes the bank.
+type Loan struct {
+	ID int
+
+	// Principal is what is left to repay, in
+	// Installments of principal every tick.
+	Principal   float64
+	Installment float64
+
+	// Missed is how many installments in a row went unpaid.
+	Missed int
+}
+
+// Statement is an agent's standing with the bank.
+type Statement struct {
+	Deposit        float64
+	Debt           float64
+	InterestEarned float64
+	InterestPaid   float64
+	Repaid         int
+	Defaults       int
+
+	// WrittenOff is what the bank wrote off on the
+	// agent's defaults and has not recovered since.
+	WrittenOff float64
+}
+
+// BankReport is what a Bank did over a tick, and what
+// it held and was owed at the end of it.
+type BankReport struct {
+	Reserves float64
+	Deposits float64
+	Loans    float64
+
+	Lent            float64
+	Repaid          float64
+	InterestCharged float64
+	InterestPaid    float64
+	WrittenOff      float64
+	Recovered       float64
+	Defaults        int
+}
+
+// Bank takes deposits and lends out of its reserves. It
+// knows its customers by name. A nil Bank does nothing.
+type Bank struct {
+	Policy BankPolicy
+
+	// Ledger, when set, records all cash
+	// moving in and out of the bank.
+	Ledger *Ledger
+
+	reserves float64
+	accounts map[string]*bankAccount
+	loans    int
+	report   BankReport
+	rwLock   sync.Mutex
+}
+
+type bankAccount struct {
+	Statement
+	loans []*Loan
+}
+
+// NewBank returns a Bank holding the capital of policy.
+func NewBank(policy BankPolicy) *Bank {
+	return &Bank{
+		Policy:   policy,
+		reserves: policy.Capital,
+		accounts: map[string]*bankAccount{},
+	}
+}
+
+// account returns the account of name.
+// The caller must hold b.rwLock.
+func (b *Bank) account(name string) *bankAccount {
+	acc, ok := b.accounts[name]
+	if !ok {
+		acc = &bankAccount{}
+		b.accounts[name] = acc
+	}
+	return acc
+}
+
+// Reserves returns the cash the bank holds.
+func (b *Bank) Reserves() float64 {
+	if b == nil {
+		return 0
+	}
+	b.rwLock.Lock()
+	defer b.rwLock.Unlock()
+
+	return b.reserves
+}
+
+// Statement returns the standing of name with the bank.
+func (b *Bank) Statement(name string) Statement {
+	if b == nil {
+		return Statement{}
+	}
+	b.rwLock.Lock()
+	defer b.rwLock.Unlock()
+
+	acc := b.account(name)
+	s := acc.Statement
+	for _, l := range acc.loans {
+		s.Debt += l.Principal
+	}
+	return s
+}
+
+// Deposit moves amount of a's cash into its account.
+// It reports whether a had the cash to deposit.
+func (b *Bank) Deposit(a *Agent, amount float64) bool {
+	if b == nil || amount <= 0 {
+		return false
+	}
+
+	if !deliver(a.TransactionChannel, Transaction{
+		CashOut: amount,
+		Memo:    "Deposit",
+		From:    AccountBank,
+	}) {
+		return false
+	}
+
+	b.rwLock.Lock()
+	b.reserves += amount
+	b.account(a.Name).Deposit += amount
+	b.rwLock.Unlock()
+
+	b.Ledger.Transfer(a.Name, AccountBank, amount, "Deposit")
+	return true
+}
+
+// Withdraw pays a up to amount out of its account,
+// as far as the bank's reserves allow, and returns
+// what was paid.
+func (b *Bank) Withdraw(a *Agent, amount float64) float64 {
+	if b == nil || amount <= 0 {
+		return 0
+	}
+
+	b.rwLock.Lock()
+	acc := b.account(a.Name)
+	amount = math.Min(amount, math.Min(acc.Deposit, b.reserves))
+	acc.Deposit -= amount
+	b.reserves -= amount
+	b.rwLock.Unlock()
+
+	b.pay(a, amount, "Withdrawal")
+	return amount
+}
+
+// CreditLimit returns how much more the bank would lend a,
+// against its collateral and its history of repayment.
+func (b *Bank) CreditLimit(a *Agent) float64 {
+	if b == nil {
+		return 0
+	}
+	collateral := a.Collateral()
+
+	b.rwLock.Lock()
+	defer b.rwLock.Unlock()
+
+	return b.creditLimit(b.account(a.Name), collateral)
+}
+
+// creditLimit is CreditLimit for acc.
+// The caller must hold b.rwLock.
+func (b *Bank) creditLimit(acc *bankAccount, collateral float64) float64 {
+	history := math.Max(0, float64(acc.Repaid-acc.Defaults))
+	limit := collateral*b.Policy.LoanToValue + history*b.Policy.CreditPerRepayment
+	for _, l := range acc.loans {
+		limit -= l.Principal
+	}
+	return math.Max(0, limit)
+}
+
+// Lend lends a up to amount, as far as its credit limit and
+// the bank's reserves beyond ReserveRatio of its deposits
+// allow, and returns what was lent.
+func (b *Bank) Lend(a *Agent, amount float64) float64 {
+	if b == nil || amount <= 0 {
+		return 0
+	}
+	collateral := a.Collateral()
+
+	b.rwLock.Lock()
+	acc := b.account(a.Name)
+	deposits, _ := b.totals()
+	lendable := b.reserves - deposits*b.Policy.ReserveRatio
+	amount = math.Min(amount, math.Min(b.creditLimit(acc, collateral), lendable))
+	if amount <= 0 {
+		b.rwLock.Unlock()
+		return 0
+	}
+
+	term := b.Policy.Term
+	if term < 1 {
+		term = 1
+	}
+	b.loans++
+	acc.loans = append(acc.loans, &Loan{
+		ID:          b.loans,
+		Principal:   amount,
+		Installment: amount / float64(term),
+	})
+	b.reserves -= amount
+	b.report.Lent += amount
+	id := b.loans
+	b.rwLock.Unlock()
+
+	b.pay(a, amount, fmt.Sprintf("Loan %d", id))
+	fmtDebug("%s borrowed %.2f from the bank.\n", a.Name, amount)
+	return amount
+}
+
+// Service pays every agent interest on its deposit, and has
+// every borrower pay the interest and installment due on
+// each of its loans, out of its deposit first and then its
+// cash. A borrower that misses more than Grace installments
+// in a row defaults: the bank takes what it can of its
+// deposit and cash, and writes the rest off.
+func (b *Bank) Service(agents []*Agent) {
+	if b == nil {
+		return
+	}
+
+	for _, a := range agents {
+		b.rwLock.Lock()
+		acc := b.account(a.Name)
+		interest := acc.Deposit * b.Policy.DepositRate
+		acc.Deposit += interest
+		acc.InterestEarned += interest
+		b.report.InterestPaid += interest
+		loans := append([]*Loan{}, acc.loans...)
+		b.rwLock.Unlock()
+
+		for _, l := range loans {
+			b.service(a, l)
+		}
+	}
+}
+
+// service collects what is due on l from a.
+func (b *Bank) service(a *Agent, l *Loan) {
+	b.rwLock.Lock()
+	acc := b.account(a.Name)
+	interest := l.Principal * b.Policy.LoanRate
+	installment := math.Min(l.Installment, l.Principal)
+	due := interest + installment
+
+	// The deposit is already in the bank
+	fromDeposit := math.Min(due, acc.Deposit)
+	acc.Deposit -= fromDeposit
+	b.rwLock.Unlock()
+
+	fromCash := due - fromDeposit
+	memo := fmt.Sprintf("Loan %d", l.ID)
+	if fromCash > 0 && !b.collect(a, fromCash, memo) {
+		b.rwLock.Lock()
+		acc.Deposit += fromDeposit
+		l.Missed++
+		missed := l.Missed
+		b.rwLock.Unlock()
+
+		if missed > b.Policy.Grace {
+			b.foreclose(a, l)
+		}
+		return
+	}
+
+	b.rwLock.Lock()
+	defer b.rwLock.Unlock()
+
+	l.Missed = 0
+	l.Principal -= installment
+	acc.InterestPaid += interest
+	b.report.InterestCharged += interest
+	b.report.Repaid += installment
+	if l.Principal < 1e-9 {
+		acc.Repaid++
+		b.remove(acc, l)
+	}
+}
+
+// foreclose puts a in default on l, taking what it
+// can of a's deposit and cash and writing the rest off.
+func (b *Bank) foreclose(a *Agent, l *Loan) {
+	b.rwLock.Lock()
+	acc := b.account(a.Name)
+	seized := math.Min(l.Principal, acc.Deposit)
+	acc.Deposit -= seized
+	owed := l.Principal - seized
+	b.rwLock.Unlock()
+
+	if cash := math.Min(owed, a.Available()); cash > 0 && b.collect(a, cash, fmt.Sprintf("Loan %d default", l.ID)) {
+		seized += cash
+	}
+
+	b.rwLock.Lock()
+	defer b.rwLock.Unlock()
+
+	b.report.Repaid += seized
+	b.report.WrittenOff += l.Principal - seized
+	b.report.Defaults++
+	acc.Defaults++
+	acc.WrittenOff += l.Principal - seized
+	b.remove(acc, l)
+	fmtDebug("%s defaulted on loan %d, %.2f written off.\n", a.Name, l.ID, l.Principal-seized)
+}
+
+// Close forecloses on every loan of a, and pays
+// out whatever is left of its deposit.
+func (b *Bank) Close(a *Agent) {
+	if b == nil {
+		return
+	}
+
+	b.rwLock.Lock()
+	loans := append([]*Loan{}, b.account(a.Name).loans...)
+	b.rwLock.Unlock()
+
+	for _, l := range loans {
+		b.foreclose(a, l)
+	}
+	b.Withdraw(a, b.Statement(a.Name).Deposit)
+}
+
+// Recover takes up to amount of a's cash towards what the
+// bank wrote off on it, and returns what was taken.
+func (b *Bank) Recover(a *Agent, amount float64) float64 {
+	if b == nil || amount <= 0 {
+		return 0
+	}
+
+	b.rwLock.Lock()
+	amount = math.Min(amount, b.account(a.Name).WrittenOff)
+	b.rwLock.Unlock()
+
+	if amount <= 0 || !b.collect(a, amount, "Recovery") {
+		return 0
+	}
+
+	b.rwLock.Lock()
+	defer b.rwLock.Unlock()
+
+	b.account(a.Name).WrittenOff -= amount
+	b.report.Recovered += amount
+	return amount
+}
+
+// remove drops l from acc. The caller must hold b.rwLock.
+func (b *Bank) remove(acc *bankAccount, l *Loan) {
+	kept := acc.loans[:0]
+	for _, other := range acc.loans {
+		if other != l {
+			kept = append(kept, other)
+		}
+	}
+	acc.loans = kept
+}
+
+// collect takes amount of a's cash into the bank's
+// reserves, reporting whether a could pay it.
+func (b *Bank) collect(a *Agent, amount float64, memo string) bool {
+	if !deliver(a.TransactionChannel, Transaction{
+		CashOut: amount,
+		Memo:    memo,
+		From:    AccountBank,
+	}) {
+		return false
+	}
+
+	b.rwLock.Lock()
+	b.reserves += amount
+	b.rwLock.Unlock()
+
+	b.Ledger.Transfer(a.Name, AccountBank, amount, memo)
+	return true
+}
+
+// pay pays a amount already taken out of the reserves.
+func (b *Bank) pay(a *Agent, amount float64, memo string) {
+	if amount <= 0 {
+		return
+	}
+	b.Ledger.Transfer(AccountBank, a.Name, amount, memo)
+	deliver(a.TransactionChannel, Transaction{
+		CashIn: amount,
+		Memo:   memo,
+		From:   AccountBank,
+	})
+}
+
+// Report returns what the bank did since it was last
+// called, and what it holds and is owed.
+func (b *Bank) Report() BankReport {
+	if b == nil {
+		return BankReport{}
+	}
+	b.rwLock.Lock()
+	defer b.rwLock.Unlock()
+
+	report := b.report
+	report.Reserves = b.reserves
+	report.Deposits, report.Loans = b.totals()
+	b.report = BankReport{}
+	return report
+}
+
+// totals returns what the bank owes its depositors and
+// is owed by its borrowers, summed in a fixed order.
+// The caller must hold b.rwLock.
+func (b *Bank) totals() (float64, float64) {
+	names := []string{}
+	for name := range b.accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	deposits, loans := 0.0, 0.0
+	for _, name := range names {
+		acc := b.accounts[name]
+		deposits += acc.Deposit
+		for _, l := range acc.loans {
+			loans += l.Principal
+		}
+	}
+	return deposits, loans
+}
@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"eco/lib/producer"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBank(t *testing.T) {
+	m := NewMarket()
+	l := NewLaborMarket()
+	ledger := NewLedger()
+	b := NewBank(BankPolicy{
+		Capital:            100,
+		LoanRate:           .1,
+		Term:               2,
+		LoanToValue:        .5,
+		CreditPerRepayment: 10,
+		Grace:              1,
+		ReserveRatio:       .5,
+	})
+	b.Ledger = ledger
+	ledger.Transfer(AccountEndowment, AccountBank, 100, "Bank capital")
+
+	saver := NewAgent(&m, &l)
+	saver.Name = "saver"
+	saver.Cash = 60
+	ledger.Transfer(AccountEndowment, saver.Name, 60, "Starting cash")
+
+	borrower := NewAgent(&m, &l)
+	borrower.Name = "borrower"
+	borrower.Cash = 100
+	borrower.Producers = []producer.Producer{producer.NewOrchard()}
+	ledger.Transfer(AccountEndowment, borrower.Name, 100, "Starting cash")
+
+	agents := []*Agent{&saver, &borrower}
+	for _, a := range agents {
+		a.Start()
+		defer a.Quit()
+	}
+	check := func() {
+		assert.NoError(t, ledger.Check(map[string]float64{
+			AccountBank:   b.Reserves(),
+			saver.Name:    saver.Balance(),
+			borrower.Name: borrower.Balance(),
+		}))
+	}
+
+	assert.True(t, b.Deposit(&saver, 40))
+	assert.False(t, b.Deposit(&saver, 40))
+	assert.Equal(t, 20.0, saver.Balance())
+	assert.Equal(t, 140.0, b.Reserves())
+
+	// Against half of what the orchard is worth
+	limit := borrower.Collateral() * .5
+	assert.Equal(t, limit, b.CreditLimit(&borrower))
+	lent := b.Lend(&borrower, 1000)
+	assert.Equal(t, limit, lent)
+	assert.Equal(t, 100+lent, borrower.Balance())
+	assert.Equal(t, 0.0, b.CreditLimit(&borrower))
+	check()
+
+	// Two installments with interest on what is left
+	b.Service(agents)
+	b.Service(agents)
+	s := b.Statement(borrower.Name)
+	assert.Equal(t, 0.0, s.Debt)
+	assert.Equal(t, 1, s.Repaid)
+	assert.InDelta(t, lent*.1+lent/2*.1, s.InterestPaid, 1e-9)
+	assert.InDelta(t, 100-s.InterestPaid, borrower.Balance(), 1e-9)
+	check()
+
+	// The repaid loan is now worth some unsecured credit
+	assert.Equal(t, limit+10, b.CreditLimit(&borrower))
+
+	// With nothing left to pay with, the borrower misses
+	// an installment, then defaults and is written off
+	borrower.Cash = 0
+	ledger.Transfer(borrower.Name, AccountEndowment, 100-s.InterestPaid, "Spent")
+	b.Lend(&borrower, 10)
+	borrower.Cash = 0
+	ledger.Transfer(borrower.Name, AccountEndowment, 10, "Spent")
+
+	b.Service(agents)
+	assert.Equal(t, 10.0, b.Statement(borrower.Name).Debt)
+	b.Service(agents)
+	s = b.Statement(borrower.Name)
+	assert.Equal(t, 0.0, s.Debt)
+	assert.Equal(t, 1, s.Defaults)
+	check()
+
+	r := b.Report()
+	assert.Equal(t, 1, r.Defaults)
+	assert.Equal(t, 10.0, r.WrittenOff)
+	assert.Equal(t, 40.0, r.Deposits)
+	assert.Equal(t, 10.0, s.WrittenOff)
+
+	// Whatever the borrower takes in later goes to
+	// what was written off, and no further
+	borrower.Cash = 15
+	ledger.Transfer(AccountEndowment, borrower.Name, 15, "Sold")
+	assert.Equal(t, 10.0, b.Recover(&borrower, 15))
+	assert.Equal(t, 0.0, b.Recover(&borrower, 5))
+	assert.Equal(t, 5.0, borrower.Balance())
+	assert.Equal(t, 0.0, b.Statement(borrower.Name).WrittenOff)
+	assert.Equal(t, 10.0, b.Report().Recovered)
+	check()
+
+	assert.Equal(t, 40.0, b.Withdraw(&saver, 100))
+	assert.Equal(t, 60.0, saver.Balance())
+	check()
+}
@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"math"
+)
+
+// reservation is cash an agent has set aside for
+// its outstanding bid at one key.
+type reservation struct {
+	Ref  int
+	Cash float64
+}
+
+// Allocate splits cash across orders in proportion to their
+// Priority, never giving an order more than it asks for: a
+// limit order's Quantity at its Price, or a market order's
+// Cash. What one order cannot use is shared among the rest.
+func Allocate(cash float64, orders []Order) []float64 {
+	shares := make([]float64, len(orders))
+
+	caps := make([]float64, len(orders))
+	open := []int{}
+	for i, o := range orders {
+		caps[i] = o.Cash
+		if o.Price > 0 {
+			caps[i] = math.Min(caps[i], float64(o.Quantity)*o.Price)
+		}
+		if o.Quantity < 1 || caps[i] <= 0 {
+			continue
+		}
+		open = append(open, i)
+	}
+
+	for len(open) > 0 && cash > 0 {
+		weight := 0.0
+		for _, i := range open {
+			weight += priority(orders[i])
+		}
+
+		// Fill every order its share would cover, then
+		// split again what is left among the rest
+		capped := false
+		rest := []int{}
+		for _, i := range open {
+			share := cash * priority(orders[i]) / weight
+			if caps[i]-shares[i] <= share {
+				capped = true
+				continue
+			}
+			rest = append(rest, i)
+		}
+
+		if !capped {
+			for _, i := range open {
+				shares[i] += cash * priority(orders[i]) / weight
+			}
+			break
+		}
+
+		for _, i := range open {
+			if !contains(rest, i) {
+				cash -= caps[i] - shares[i]
+				shares[i] = caps[i]
+			}
+		}
+		open = rest
+	}
+	return shares
+}
+
+func priority(o Order) float64 {
+	if o.Priority < 1 {
+		return 1
+	}
+	return float64(o.Priority)
+}
+
+func contains(indices []int, i int) bool {
+	for _, j := range indices {
+		if j == i {
+			return true
+		}
+	}
+	return false
+}
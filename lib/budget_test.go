@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"eco/lib/consumable"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAllocate(t *testing.T) {
+	apple := consumable.NewApple()
+
+	// Split by priority when nothing is capped
+	shares := Allocate(90, []Order{
+		{Consumable: apple, Quantity: 100, Price: 1, Cash: 1000, Priority: 2},
+		{Consumable: apple, Quantity: 100, Price: 1, Cash: 1000},
+	})
+	assert.InDeltaSlice(t, []float64{60, 30}, shares, 0.001)
+
+	// What a small order cannot use goes to the rest
+	shares = Allocate(90, []Order{
+		{Consumable: apple, Quantity: 5, Price: 2, Cash: 1000, Priority: 5},
+		{Consumable: apple, Quantity: 100, Price: 1, Cash: 1000},
+		{Consumable: apple, Quantity: 100, Price: 1, Cash: 1000},
+	})
+	assert.InDeltaSlice(t, []float64{10, 40, 40}, shares, 0.001)
+
+	// Market orders are capped by their Cash
+	shares = Allocate(90, []Order{
+		{Consumable: apple, Quantity: 100, Cash: 15},
+		{Consumable: apple, Quantity: 0, Price: 1, Cash: 1000},
+		{Consumable: apple, Quantity: 100, Price: 1, Cash: 1000},
+	})
+	assert.InDeltaSlice(t, []float64{15, 0, 75}, shares, 0.001)
+
+	total := 0.0
+	for _, s := range Allocate(50, []Order{
+		{Consumable: apple, Quantity: 30, Price: 1, Cash: 50, Priority: 3},
+		{Consumable: apple, Quantity: 30, Price: 1, Cash: 50, Priority: 1},
+	}) {
+		total += s
+	}
+	assert.InDelta(t, 50.0, total, 0.001)
+}
+
+func TestReservedCashIsNotSpentTwice(t *testing.T) {
+	m := NewMarket()
+	l := NewLaborMarket()
+	a := NewAgent(&m, &l)
+	a.Cash = 100
+	a.reserved[consumable.KeyApple] = reservation{Ref: 1, Cash: 80}
+
+	assert.Equal(t, 20.0, a.Available())
+
+	a.Start()
+	defer a.Quit()
+
+	// Another order cannot draw on the reservation
+	accepted := deliver(a.TransactionChannel, Transaction{
+		Phase:         PhaseReserve,
+		Escrow:        1,
+		CashOut:       30,
+		ConsumableKey: consumable.KeyApple,
+		OrderRef:      2,
+	})
+	assert.False(t, accepted)
+
+	// The order it was made for can
+	accepted = deliver(a.TransactionChannel, Transaction{
+		Phase:         PhaseReserve,
+		Escrow:        2,
+		CashOut:       90,
+		ConsumableKey: consumable.KeyApple,
+		OrderRef:      1,
+	})
+	assert.True(t, accepted)
+	assert.Equal(t, 10.0, a.Available())
+
+	// A fill that leaves nothing resting frees the rest
+	deliver(a.TransactionChannel, Transaction{
+		Fill: &Fill{Key: consumable.KeyApple, OrderRef: 1},
+	})
+	assert.Equal(t, 10.0, a.Available())
+	assert.Empty(t, a.reserved)
+}
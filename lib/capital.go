@@ -0,0 +1,233 @@
+package lib
+
+import (
+	"eco/lib/producer"
+	"fmt"
+	"sync"
+)
+
+// ScrapCondition is the condition below which a producer
+// is worn out, and scrapped once it can be done without.
+const ScrapCondition = .1
+
+// worn reports whether p is worn out.
+func worn(p producer.Producer) bool {
+	return p.Condition() < ScrapCondition
+}
+
+// ProducerListing is a used producer for sale. It sells
+// at its Value, which falls as it wears.
+type ProducerListing struct {
+	Producer producer.Producer
+	Seller   *Agent
+}
+
+// CapitalReport is what changed hands on a CapitalMarket
+// over a tick, and what was left for sale at the end of it.
+type CapitalReport struct {
+	Built    int
+	Resold   int
+	Scrapped int
+
+	// Invested is what was spent on producers, new and used.
+	Invested float64
+
+	Listed int
+}
+
+// CapitalMarket sells producers: used ones listed by the
+// agents that owned them, and new ones built from its
+// Catalog. A nil CapitalMarket sells nothing.
+type CapitalMarket struct {
+	Catalog *Catalog
+
+	// Ledger, when set, records what is paid for producers.
+	Ledger *Ledger
+
+	listings []ProducerListing
+	report   CapitalReport
+	rwLock   sync.Mutex
+}
+
+// NewCapitalMarket returns a CapitalMarket building
+// the producers in catalog.
+func NewCapitalMarket(catalog *Catalog) *CapitalMarket {
+	return &CapitalMarket{Catalog: catalog}
+}
+
+// List puts p, which seller no longer runs, up for sale.
+func (c *CapitalMarket) List(seller *Agent, p producer.Producer) {
+	if c == nil {
+		return
+	}
+	c.rwLock.Lock()
+	defer c.rwLock.Unlock()
+
+	c.listings = append(c.listings, ProducerListing{Producer: p, Seller: seller})
+	fmtDebug("%s put up a used %s for %.2f.\n", seller.Name, p.Key(), p.Value())
+}
+
+// Listings returns the used producers for sale,
+// in the order they were listed.
+func (c *CapitalMarket) Listings() []ProducerListing {
+	if c == nil {
+		return nil
+	}
+	c.rwLock.Lock()
+	defer c.rwLock.Unlock()
+
+	return append([]ProducerListing{}, c.listings...)
+}
+
+// Quote returns what a producer of key costs, used if one
+// is for sale and new otherwise, and whether one can be had.
+func (c *CapitalMarket) Quote(key string) (float64, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.rwLock.Lock()
+	defer c.rwLock.Unlock()
+
+	if i := c.best(key); i >= 0 {
+		return c.listings[i].Producer.Value(), true
+	}
+	p, err := c.Catalog.Producers.New(key)
+	if err != nil {
+		return 0, false
+	}
+	return p.Value(), true
+}
+
+// best returns the index of the listing of key in the best
+// condition, or -1 when none is for sale. The caller must
+// hold c.rwLock.
+func (c *CapitalMarket) best(key string) int {
+	best := -1
+	for i, l := range c.listings {
+		if l.Producer.Key() != key {
+			continue
+		}
+		if best < 0 || l.Producer.Condition() > c.listings[best].Producer.Condition() {
+			best = i
+		}
+	}
+	return best
+}
+
+// Buy sells buyer a producer of key at its Value: the used
+// one in the best condition if any is for sale, or a new one.
+// It returns nil when none can be had or buyer cannot pay.
+func (c *CapitalMarket) Buy(buyer *Agent, key string) producer.Producer {
+	if c == nil {
+		return nil
+	}
+
+	// A listing is taken off the market while it is
+	// paid for, so that no one else can buy it
+	c.rwLock.Lock()
+	var listing ProducerListing
+	if i := c.best(key); i >= 0 {
+		listing = c.listings[i]
+		c.listings = append(c.listings[:i], c.listings[i+1:]...)
+	}
+	c.rwLock.Unlock()
+
+	p := listing.Producer
+	if p == nil {
+		built, err := c.Catalog.Producers.New(key)
+		if err != nil {
+			return nil
+		}
+		p = built
+	}
+
+	price := p.Value()
+	memo := fmt.Sprintf("Bought a new %s", key)
+	if listing.Seller != nil {
+		memo = fmt.Sprintf("Bought a used %s from %s", key, listing.Seller.Name)
+	}
+	if !deliver(buyer.TransactionChannel, Transaction{
+		CashOut: price,
+		Memo:    memo,
+		From:    key,
+	}) {
+		if listing.Seller != nil {
+			c.rwLock.Lock()
+			c.listings = append(c.listings, listing)
+			c.rwLock.Unlock()
+		}
+		return nil
+	}
+
+	if listing.Seller != nil {
+		c.Ledger.Transfer(buyer.Name, listing.Seller.Name, price, memo)
+		deliver(listing.Seller.TransactionChannel, Transaction{
+			CashIn:  price,
+			Memo:    fmt.Sprintf("Sold a used %s", key),
+			Capital: true,
+			From:    buyer.Name,
+		})
+	} else {
+		c.Ledger.Transfer(buyer.Name, ProductionAccount(key), price, memo)
+	}
+
+	c.rwLock.Lock()
+	if listing.Seller != nil {
+		c.report.Resold++
+	} else {
+		c.report.Built++
+	}
+	c.report.Invested += price
+	c.rwLock.Unlock()
+
+	fmtDebug("%s: %s for %.2f.\n", buyer.Name, memo, price)
+	return p
+}
+
+// Scrap records that owner scrapped p.
+func (c *CapitalMarket) Scrap(owner *Agent, p producer.Producer) {
+	if c == nil {
+		return
+	}
+	c.rwLock.Lock()
+	defer c.rwLock.Unlock()
+
+	c.report.Scrapped++
+	fmtDebug("%s scrapped a worn out %s.\n", owner.Name, p.Key())
+}
+
+// Depreciate wears every producer for sale by a tick, and
+// scraps those worn out.
+func (c *CapitalMarket) Depreciate() {
+	if c == nil {
+		return
+	}
+	c.rwLock.Lock()
+	defer c.rwLock.Unlock()
+
+	kept := c.listings[:0]
+	for _, l := range c.listings {
+		l.Producer.Depreciate()
+		if worn(l.Producer) {
+			c.report.Scrapped++
+			continue
+		}
+		kept = append(kept, l)
+	}
+	c.listings = kept
+}
+
+// Report returns what changed hands since it was
+// last called, and what is left for sale.
+func (c *CapitalMarket) Report() CapitalReport {
+	if c == nil {
+		return CapitalReport{}
+	}
+	c.rwLock.Lock()
+	defer c.rwLock.Unlock()
+
+	report := c.report
+	report.Listed = len(c.listings)
+	c.report = CapitalReport{}
+	return report
+}
@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"eco/lib/producer"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func groveCatalog(t *testing.T) *Catalog {
+	c, err := ReadCatalog(strings.NewReader(`{
+		"consumables": [{"key": "pear", "value": 0.5, "scale": 2, "shelfLife": 4}],
+		"producers": [{"key": "grove", "output": "pear", "rate": 3, "cost": 1, "wage": 2,
+			"value": 100, "depreciation": 0.5, "maintenance": 5}]
+	}`))
+	assert.NoError(t, err)
+	return c
+}
+
+func TestCapitalMarket(t *testing.T) {
+	catalog := groveCatalog(t)
+	ledger := NewLedger()
+	c := NewCapitalMarket(catalog)
+	c.Ledger = ledger
+
+	m := NewMarket()
+	l := NewLaborMarket()
+	seller := NewAgent(&m, &l)
+	seller.Name = "seller"
+	buyer := NewAgent(&m, &l)
+	buyer.Name = "buyer"
+	buyer.Cash = 200
+	ledger.Transfer(AccountEndowment, buyer.Name, 200, "Starting cash")
+	for _, a := range []*Agent{&seller, &buyer} {
+		a.Start()
+		defer a.Quit()
+	}
+
+	// Used at half its value after a tick of wear
+	used, _ := catalog.Producers.New("grove")
+	used.Depreciate()
+	c.List(&seller, used)
+	price, ok := c.Quote("grove")
+	assert.True(t, ok)
+	assert.Equal(t, 50.0, price)
+
+	assert.Equal(t, used, c.Buy(&buyer, "grove"))
+	assert.Equal(t, 150.0, buyer.Balance())
+	assert.Equal(t, 50.0, seller.Balance())
+
+	// Selling a producer is not trade
+	assert.Equal(t, 50.0, seller.Report.Divested)
+	assert.Equal(t, 0.0, seller.Report.Revenue)
+	assert.Empty(t, seller.sold)
+
+	// With none used left it is built new
+	built := c.Buy(&buyer, "grove")
+	if assert.NotNil(t, built) {
+		assert.Equal(t, 1.0, built.Condition())
+	}
+	assert.Equal(t, 50.0, buyer.Balance())
+	assert.Nil(t, c.Buy(&buyer, "grove"))
+	_, ok = c.Quote("mine")
+	assert.False(t, ok)
+
+	assert.NoError(t, ledger.Check(map[string]float64{
+		seller.Name: seller.Balance(),
+		buyer.Name:  buyer.Balance(),
+	}))
+
+	r := c.Report()
+	assert.Equal(t, 1, r.Built)
+	assert.Equal(t, 1, r.Resold)
+	assert.Equal(t, 150.0, r.Invested)
+
+	// Producers for sale wear until they are scrapped
+	c.List(&seller, built)
+	for i := 0; i < 4; i++ {
+		c.Depreciate()
+	}
+	assert.Empty(t, c.Listings())
+	r = c.Report()
+	assert.Equal(t, 1, r.Scrapped)
+	assert.Equal(t, 0, r.Listed)
+}
+
+func TestMaintain(t *testing.T) {
+	catalog := groveCatalog(t)
+	m := NewMarket()
+	l := NewLaborMarket()
+	a := NewAgent(&m, &l)
+	a.Capital = NewCapitalMarket(catalog)
+	a.Cash = 5
+	a.Start()
+	defer a.Quit()
+
+	worn, _ := catalog.Producers.New("grove")
+	for i := 0; i < 3; i++ {
+		worn.Depreciate()
+	}
+	fresh, _ := catalog.Producers.New("grove")
+	a.Producers = []producer.Producer{fresh, worn}
+
+	// Only the first can be paid for, and the
+	// worn out one goes now that there is another
+	a.Maintain()
+	assert.Equal(t, 0.0, a.Balance())
+	assert.Equal(t, 5.0, a.Report.Maintenance)
+	assert.Equal(t, 50+6.25, a.Report.Depreciation)
+	assert.Equal(t, []producer.Producer{fresh}, a.Producers)
+	assert.True(t, a.unmaintained[worn])
+	assert.Equal(t, 1, a.Capital.Report().Scrapped)
+
+	// The last producer is run however worn
+	a.Maintain()
+	a.Maintain()
+	a.Maintain()
+	assert.Equal(t, []producer.Producer{fresh}, a.Producers)
+	assert.True(t, a.unmaintained[fresh])
+}
@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"eco/lib/consumable"
+	"eco/lib/producer"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// CatalogFile is the JSON layout of a catalog.
+type CatalogFile struct {
+	Consumables []consumable.Definition `json:"consumables"`
+	Producers   []producer.Definition   `json:"producers"`
+}
+
+// Catalog holds the goods and producers of a scenario.
+type Catalog struct {
+	Goods     *consumable.Registry
+	Producers *producer.Registry
+}
+
+// NewCatalog registers the definitions in f.
+func NewCatalog(f CatalogFile) (*Catalog, error) {
+	goods := consumable.NewRegistry()
+	for _, d := range f.Consumables {
+		if err := goods.Register(d); err != nil {
+			return nil, err
+		}
+	}
+
+	producers := producer.NewRegistry(goods)
+	for _, d := range f.Producers {
+		if err := producers.Register(d); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Catalog{Goods: goods, Producers: producers}, nil
+}
+
+// DefaultCatalog returns a catalog of apples and the
+// orchards that grow them.
+func DefaultCatalog() *Catalog {
+	c, err := NewCatalog(CatalogFile{
+		Consumables: []consumable.Definition{
+			{Key: consumable.KeyApple, Value: .25, Scale: 5, ShelfLife: 20},
+		},
+		Producers: []producer.Definition{
+			{Key: producer.KeyOrchard, Output: consumable.KeyApple, Rate: 10, Cost: 1, Wage: 5, Value: 200, Depreciation: .01, Maintenance: 2},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// ReadCatalog reads a JSON catalog from r.
+func ReadCatalog(r io.Reader) (*Catalog, error) {
+	f := CatalogFile{}
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&f); err != nil {
+		return nil, fmt.Errorf("catalog: %w", err)
+	}
+	return NewCatalog(f)
+}
+
+// LoadCatalog reads the JSON catalog at path.
+func LoadCatalog(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadCatalog(f)
+}
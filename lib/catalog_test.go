@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestReadCatalog(t *testing.T) {
+	c, err := ReadCatalog(strings.NewReader(`{
+		"consumables": [{"key": "pear", "value": 0.5, "scale": 2, "shelfLife": 4}],
+		"producers": [{"key": "grove", "output": "pear", "rate": 3, "cost": 1.5, "wage": 2, "value": 10}]
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"pear"}, c.Goods.Keys())
+	assert.Equal(t, []string{"grove"}, c.Producers.Keys())
+
+	p, err := c.Producers.New("grove")
+	if !assert.NoError(t, err) {
+		return
+	}
+	cost, wage, lot := p.Produce()
+	assert.Equal(t, 4.5, cost)
+	assert.Equal(t, 6.0, wage)
+	if assert.Equal(t, 3, lot.Quantity) {
+		assert.Equal(t, "pear", lot.Key())
+		assert.Equal(t, 2, lot.Consumable.Scale())
+	}
+
+	_, err = ReadCatalog(strings.NewReader(`{
+		"producers": [{"key": "grove", "output": "pear", "rate": 3}]
+	}`))
+	assert.Error(t, err)
+
+	_, err = c.Producers.New("mine")
+	assert.Error(t, err)
+}
@@ -7,19 +7,25 @@ type Apple interface {
 	Scale() int
 	Clone() Consumable
 	Value() float64
+	ShelfLife() int
+	Produced() int
+	SetProduced(tick int)
 }
 
 type apple struct {
-	key   string
-	scale int
-	value float64
+	key       string
+	scale     int
+	value     float64
+	shelfLife int
+	produced  int
 }
 
 func NewApple() Apple {
 	return &apple{
-		key:   KeyApple,
-		value: .25,
-		scale: 0,
+		key:       KeyApple,
+		value:     .25,
+		scale:     5,
+		shelfLife: 20,
 	}
 }
 
@@ -35,10 +41,24 @@ func (a *apple) Value() float64 {
 	return a.value
 }
 
+func (a *apple) ShelfLife() int {
+	return a.shelfLife
+}
+
+func (a *apple) Produced() int {
+	return a.produced
+}
+
+func (a *apple) SetProduced(tick int) {
+	a.produced = tick
+}
+
 func (a *apple) Clone() Consumable {
 	return &apple{
-		key:   KeyApple,
-		scale: a.scale,
-		value: a.value,
+		key:       KeyApple,
+		scale:     a.scale,
+		value:     a.value,
+		shelfLife: a.shelfLife,
+		produced:  a.produced,
 	}
 }
@@ -13,5 +13,21 @@ type Consumable interface {
 	// Value returns the base value of this consumable
 	Value() float64
 
+	// ShelfLife returns how many ticks a unit keeps
+	// once produced. Zero never spoils.
+	ShelfLife() int
+
+	// Produced returns the tick the unit was produced.
+	Produced() int
+
+	// SetProduced stamps the unit with the
+	// tick it was produced.
+	SetProduced(tick int)
+
 	Clone() Consumable
 }
+
+// Expired reports whether c has spoiled by tick.
+func Expired(c Consumable, tick int) bool {
+	return c.ShelfLife() > 0 && tick-c.Produced() >= c.ShelfLife()
+}
@@ -1,6 +1,18 @@
 package consumable
 
+// Demand is an appetite for a Consumable. Every tick the
+// agent eats Scale units of it and bids to top its
+// holdings back up to Quantity.
 type Demand struct {
 	Consumable Consumable
 	Quantity   int
+
+	// Price is the most that will be bid per unit.
+	// Zero bids at market.
+	Price float64
+
+	// Priority weighs the demand's share of the agent's
+	// cash when it cannot cover every demand. Zero
+	// counts as one.
+	Priority int
 }
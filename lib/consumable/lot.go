@@ -0,0 +1,108 @@
+package consumable
+
+// Lot is Quantity units of a good that share everything
+// about Consumable, down to the tick they were produced.
+type Lot struct {
+	Consumable Consumable
+	Quantity   int
+}
+
+func NewLot(c Consumable, quantity int) Lot {
+	return Lot{Consumable: c, Quantity: quantity}
+}
+
+// Key returns the key of the lot's good.
+func (l Lot) Key() string {
+	return l.Consumable.Key()
+}
+
+// Lots holds goods as lots, oldest first.
+type Lots []Lot
+
+// Count returns how many units the lots hold.
+func (l Lots) Count() int {
+	count := 0
+	for _, lot := range l {
+		count += lot.Quantity
+	}
+	return count
+}
+
+// CountOf returns how many units of key the lots hold.
+func (l Lots) CountOf(key string) int {
+	count := 0
+	for _, lot := range l {
+		if lot.Key() == key {
+			count += lot.Quantity
+		}
+	}
+	return count
+}
+
+// Add returns l with lots appended, merging each into the
+// last lot when they are the same good from the same tick.
+func (l Lots) Add(lots ...Lot) Lots {
+	for _, lot := range lots {
+		if lot.Quantity < 1 {
+			continue
+		}
+		if n := len(l); n > 0 && same(l[n-1].Consumable, lot.Consumable) {
+			l[n-1].Quantity += lot.Quantity
+			continue
+		}
+		l = append(l, lot)
+	}
+	return l
+}
+
+// Take splits off the oldest quantity units. It returns
+// them and what is left, without modifying l.
+func (l Lots) Take(quantity int) (Lots, Lots) {
+	return l.take(func(Lot) bool { return true }, quantity)
+}
+
+// TakeOf splits off the oldest quantity units of key. It
+// returns them and what is left, without modifying l.
+func (l Lots) TakeOf(key string, quantity int) (Lots, Lots) {
+	return l.take(func(lot Lot) bool { return lot.Key() == key }, quantity)
+}
+
+func (l Lots) take(match func(Lot) bool, quantity int) (Lots, Lots) {
+	taken := Lots{}
+	rest := Lots{}
+	for _, lot := range l {
+		if quantity < 1 || !match(lot) {
+			rest = append(rest, lot)
+			continue
+		}
+
+		q := lot.Quantity
+		if q > quantity {
+			q = quantity
+			rest = append(rest, NewLot(lot.Consumable, lot.Quantity-q))
+		}
+		taken = append(taken, NewLot(lot.Consumable, q))
+		quantity -= q
+	}
+	return taken, rest
+}
+
+// Expire returns the lots still fresh at tick and
+// how many units have spoiled.
+func (l Lots) Expire(tick int) (Lots, int) {
+	fresh := Lots{}
+	spoiled := 0
+	for _, lot := range l {
+		if Expired(lot.Consumable, tick) {
+			spoiled += lot.Quantity
+			continue
+		}
+		fresh = append(fresh, lot)
+	}
+	return fresh, spoiled
+}
+
+// same reports whether units of a and b are interchangeable.
+func same(a Consumable, b Consumable) bool {
+	return a.Key() == b.Key() && a.Produced() == b.Produced()
+}
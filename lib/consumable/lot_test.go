@@ -0,0 +1,31 @@
+package consumable
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func picked(tick int, quantity int) Lot {
+	a := NewApple()
+	a.SetProduced(tick)
+	return NewLot(a, quantity)
+}
+
+func TestLots(t *testing.T) {
+	lots := Lots{}.Add(picked(1, 3), picked(1, 2), picked(2, 4))
+	assert.Len(t, lots, 2)
+	assert.Equal(t, 9, lots.Count())
+
+	taken, rest := lots.Take(6)
+	assert.Equal(t, Lots{picked(1, 5), picked(2, 1)}, taken)
+	assert.Equal(t, Lots{picked(2, 3)}, rest)
+	assert.Equal(t, 9, lots.Count())
+
+	taken, rest = lots.TakeOf("pear", 1)
+	assert.Empty(t, taken)
+	assert.Equal(t, lots, rest)
+
+	fresh, spoiled := lots.Expire(21)
+	assert.Equal(t, 5, spoiled)
+	assert.Equal(t, Lots{picked(2, 4)}, fresh)
+}
@@ -0,0 +1,116 @@
+package consumable
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Definition describes a good without any Go of its own,
+// as it is written in a catalog.
+type Definition struct {
+	Key   string  `json:"key"`
+	Value float64 `json:"value"`
+	Scale int     `json:"scale"`
+
+	// ShelfLife is how many ticks the good keeps.
+	// Zero never spoils.
+	ShelfLife int `json:"shelfLife,omitempty"`
+}
+
+// good is a Consumable built from a Definition.
+type good struct {
+	key       string
+	scale     int
+	value     float64
+	shelfLife int
+	produced  int
+}
+
+func (g *good) Key() string {
+	return g.key
+}
+
+func (g *good) Scale() int {
+	return g.scale
+}
+
+func (g *good) Value() float64 {
+	return g.value
+}
+
+func (g *good) ShelfLife() int {
+	return g.shelfLife
+}
+
+func (g *good) Produced() int {
+	return g.produced
+}
+
+func (g *good) SetProduced(tick int) {
+	g.produced = tick
+}
+
+func (g *good) Clone() Consumable {
+	c := *g
+	return &c
+}
+
+// Registry builds the goods it has definitions for.
+type Registry struct {
+	definitions map[string]Definition
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		definitions: map[string]Definition{},
+	}
+}
+
+// Register adds d to the registry.
+func (r *Registry) Register(d Definition) error {
+	switch {
+	case d.Key == "":
+		return fmt.Errorf("consumable: definition has no key")
+	case d.Scale < 1:
+		return fmt.Errorf("consumable: %s: scale must be at least 1", d.Key)
+	case d.Value < 0:
+		return fmt.Errorf("consumable: %s: value cannot be negative", d.Key)
+	case d.ShelfLife < 0:
+		return fmt.Errorf("consumable: %s: shelf life cannot be negative", d.Key)
+	}
+	if _, ok := r.definitions[d.Key]; ok {
+		return fmt.Errorf("consumable: %s is already registered", d.Key)
+	}
+	r.definitions[d.Key] = d
+	return nil
+}
+
+// Definition returns the definition registered under key.
+func (r *Registry) Definition(key string) (Definition, bool) {
+	d, ok := r.definitions[key]
+	return d, ok
+}
+
+// New returns a new unit of the good registered under key.
+func (r *Registry) New(key string) (Consumable, error) {
+	d, ok := r.definitions[key]
+	if !ok {
+		return nil, fmt.Errorf("consumable: unknown good %q", key)
+	}
+	return &good{
+		key:       d.Key,
+		scale:     d.Scale,
+		value:     d.Value,
+		shelfLife: d.ShelfLife,
+	}, nil
+}
+
+// Keys returns the key of every registered good, sorted.
+func (r *Registry) Keys() []string {
+	keys := []string{}
+	for key := range r.definitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,249 @@
+package lib
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// AgentRecord is the state of one agent at the end of a tick.
+// The embedded Report accumulates over the whole run.
+type AgentRecord struct {
+	Tick       int
+	Name       string
+	Cash       float64
+	SeeksWage  bool
+	IsEmployed bool
+	Report
+}
+
+// MarketRecord is the activity of one market key over a tick.
+type MarketRecord struct {
+	Tick int
+	MarketReport
+}
+
+// GovernmentRecord is what the government
+// taxed and paid over a tick.
+type GovernmentRecord struct {
+	Tick int
+	GovernmentReport
+}
+
+// BankRecord is what the bank did over a tick.
+type BankRecord struct {
+	Tick int
+	BankReport
+}
+
+// Exporter writes the records of every tick somewhere.
+type Exporter interface {
+	Export(report TickReport) error
+	Close() error
+}
+
+// ExportWriters are where an Exporter writes each kind of
+// record. A nil writer leaves that kind of record out.
+type ExportWriters struct {
+	Agents     io.Writer
+	Markets    io.Writer
+	Government io.Writer
+	Bank       io.Writer
+}
+
+// closers returns whichever of the writers are io.Closers.
+func (w ExportWriters) closers() []io.Closer {
+	c := []io.Closer{}
+	for _, w := range []io.Writer{w.Agents, w.Markets, w.Government, w.Bank} {
+		if closer, ok := w.(io.Closer); ok {
+			c = append(c, closer)
+		}
+	}
+	return c
+}
+
+// columns flattens the exported fields of a record struct,
+// including those of embedded structs, into CSV header
+// names and values. It keeps the CSV columns in step with
+// the JSON field names as Report grows.
+func columns(record interface{}) ([]string, []string) {
+	names := []string{}
+	values := []string{}
+
+	v := reflect.ValueOf(record)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			n, vs := columns(v.Field(i).Interface())
+			names = append(names, n...)
+			values = append(values, vs...)
+			continue
+		}
+		names = append(names, f.Name)
+		values = append(values, fmt.Sprint(v.Field(i).Interface()))
+	}
+	return names, values
+}
+
+// CSVExporter writes agent, market, government and bank
+// records as CSV, one row per agent or market key per tick,
+// and one row per tick for the government and the bank.
+type CSVExporter struct {
+	agents     *csv.Writer
+	markets    *csv.Writer
+	government *csv.Writer
+	bank       *csv.Writer
+	closers    []io.Closer
+	header     map[*csv.Writer]bool
+}
+
+// NewCSVExporter returns a CSVExporter writing each kind of
+// record to its writer in w. Close closes whichever of them
+// are io.Closers.
+func NewCSVExporter(w ExportWriters) *CSVExporter {
+	return &CSVExporter{
+		agents:     newCSVWriter(w.Agents),
+		markets:    newCSVWriter(w.Markets),
+		government: newCSVWriter(w.Government),
+		bank:       newCSVWriter(w.Bank),
+		closers:    w.closers(),
+		header:     map[*csv.Writer]bool{},
+	}
+}
+
+// newCSVWriter returns a csv.Writer writing to w,
+// or nil when w is nil.
+func newCSVWriter(w io.Writer) *csv.Writer {
+	if w == nil {
+		return nil
+	}
+	return csv.NewWriter(w)
+}
+
+// write writes the record to w, preceded by a header
+// row if w has none yet. A nil w writes nothing.
+func (e *CSVExporter) write(w *csv.Writer, record interface{}) error {
+	if w == nil {
+		return nil
+	}
+	names, values := columns(record)
+	if !e.header[w] {
+		e.header[w] = true
+		if err := w.Write(names); err != nil {
+			return err
+		}
+	}
+	return w.Write(values)
+}
+
+func (e *CSVExporter) Export(report TickReport) error {
+	for _, r := range report.AgentRecords {
+		if err := e.write(e.agents, r); err != nil {
+			return err
+		}
+	}
+	for _, r := range report.Markets {
+		if err := e.write(e.markets, r); err != nil {
+			return err
+		}
+	}
+	if err := e.write(e.government, report.Government); err != nil {
+		return err
+	}
+	if err := e.write(e.bank, report.Bank); err != nil {
+		return err
+	}
+
+	for _, w := range []*csv.Writer{e.agents, e.markets, e.government, e.bank} {
+		if w == nil {
+			continue
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *CSVExporter) Close() error {
+	return closeAll(e.closers)
+}
+
+// JSONLExporter writes agent, market, government and bank
+// records as JSON Lines, one object per agent or market key
+// per tick, and one per tick for the government and the bank.
+type JSONLExporter struct {
+	agents     *json.Encoder
+	markets    *json.Encoder
+	government *json.Encoder
+	bank       *json.Encoder
+	closers    []io.Closer
+}
+
+// NewJSONLExporter returns a JSONLExporter writing each
+// kind of record to its writer in w. Close closes whichever
+// of them are io.Closers.
+func NewJSONLExporter(w ExportWriters) *JSONLExporter {
+	return &JSONLExporter{
+		agents:     newEncoder(w.Agents),
+		markets:    newEncoder(w.Markets),
+		government: newEncoder(w.Government),
+		bank:       newEncoder(w.Bank),
+		closers:    w.closers(),
+	}
+}
+
+// newEncoder returns a json.Encoder writing to w,
+// or nil when w is nil.
+func newEncoder(w io.Writer) *json.Encoder {
+	if w == nil {
+		return nil
+	}
+	return json.NewEncoder(w)
+}
+
+// encode writes the record to enc. A nil enc writes nothing.
+func encode(enc *json.Encoder, record interface{}) error {
+	if enc == nil {
+		return nil
+	}
+	return enc.Encode(record)
+}
+
+func (e *JSONLExporter) Export(report TickReport) error {
+	for _, r := range report.AgentRecords {
+		if err := encode(e.agents, r); err != nil {
+			return err
+		}
+	}
+	for _, r := range report.Markets {
+		if err := encode(e.markets, r); err != nil {
+			return err
+		}
+	}
+	if err := encode(e.government, report.Government); err != nil {
+		return err
+	}
+	return encode(e.bank, report.Bank)
+}
+
+func (e *JSONLExporter) Close() error {
+	return closeAll(e.closers)
+}
+
+func closeAll(closers []io.Closer) error {
+	var first error
+	for _, c := range closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCSVExporter(t *testing.T) {
+	agents := bytes.NewBuffer([]byte{})
+	markets := bytes.NewBuffer([]byte{})
+	government := bytes.NewBuffer([]byte{})
+	bank := bytes.NewBuffer([]byte{})
+	e := NewCSVExporter(ExportWriters{
+		Agents:     agents,
+		Markets:    markets,
+		Government: government,
+		Bank:       bank,
+	})
+
+	for tick := 1; tick <= 2; tick++ {
+		assert.NoError(t, e.Export(TickReport{
+			Tick: tick,
+			AgentRecords: []AgentRecord{
+				{Tick: tick, Name: "a", Cash: 10, Report: Report{Revenue: 2.5}},
+			},
+			Markets: []MarketRecord{
+				{Tick: tick, MarketReport: MarketReport{Key: "apple", ProductSold: 3}},
+			},
+			Government: GovernmentRecord{Tick: tick, GovernmentReport: GovernmentReport{Treasury: 4, SalesTax: 1.5}},
+			Bank:       BankRecord{Tick: tick, BankReport: BankReport{Loans: 20, Defaults: 1}},
+		}))
+	}
+	assert.NoError(t, e.Close())
+
+	rows := strings.Split(strings.TrimSpace(agents.String()), "\n")
+	if assert.Len(t, rows, 3) {
+		assert.True(t, strings.HasPrefix(rows[0], "Tick,Name,Cash,SeeksWage,IsEmployed,"))
+		assert.True(t, strings.HasPrefix(rows[2], "2,a,10,false,false,"))
+	}
+
+	rows = strings.Split(strings.TrimSpace(markets.String()), "\n")
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, "Tick,Key,TotalCashFlow,TotalProductFlow,ProductReceived,ProductSold,AveragePrice,Stock,Spoiled", rows[0])
+		assert.Equal(t, "1,apple,0,0,0,3,0,0,0", rows[1])
+	}
+
+	rows = strings.Split(strings.TrimSpace(government.String()), "\n")
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, "Tick,Treasury,IncomeTax,SalesTax,CorporateTax,Uncollected,UnemploymentBenefits,BasicIncome,Shortfall", rows[0])
+		assert.Equal(t, "2,4,0,1.5,0,0,0,0,0", rows[2])
+	}
+
+	rows = strings.Split(strings.TrimSpace(bank.String()), "\n")
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, "Tick,Reserves,Deposits,Loans,Lent,Repaid,InterestCharged,InterestPaid,WrittenOff,Recovered,Defaults", rows[0])
+		assert.Equal(t, "1,0,0,20,0,0,0,0,0,0,1", rows[1])
+	}
+}
+
+func TestJSONLExporterLeavesOutUnset(t *testing.T) {
+	agents := bytes.NewBuffer([]byte{})
+	e := NewJSONLExporter(ExportWriters{Agents: agents})
+
+	assert.NoError(t, e.Export(TickReport{
+		Tick:         1,
+		AgentRecords: []AgentRecord{{Tick: 1, Name: "a"}},
+		Markets:      []MarketRecord{{Tick: 1, MarketReport: MarketReport{Key: "apple"}}},
+		Government:   GovernmentRecord{Tick: 1},
+		Bank:         BankRecord{Tick: 1},
+	}))
+	assert.NoError(t, e.Close())
+
+	rows := strings.Split(strings.TrimSpace(agents.String()), "\n")
+	if assert.Len(t, rows, 1) {
+		assert.True(t, strings.HasPrefix(rows[0], `{"Tick":1,"Name":"a",`))
+	}
+}
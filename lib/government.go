@@ -0,0 +1,248 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// AccountTreasury holds the taxes the Government collects
+// and pays its transfers out of.
+const AccountTreasury = "treasury"
+
+// Tax is a kind of tax the Government levies.
+type Tax int
+
+const (
+	// TaxIncome is withheld from wages.
+	TaxIncome Tax = iota
+
+	// TaxSales is kept back from what a buyer
+	// pays before the seller is paid.
+	TaxSales
+
+	// TaxCorporate is levied at the end of every tick
+	// on what a producer took in over it.
+	TaxCorporate
+)
+
+// Policy sets the taxes a Government levies, as shares of
+// what they are levied on, and the transfers it pays each
+// tick.
+type Policy struct {
+	IncomeTax    float64
+	SalesTax     float64
+	CorporateTax float64
+
+	// UnemploymentBenefit is paid to every agent
+	// seeking work that has no job.
+	UnemploymentBenefit float64
+
+	// BasicIncome is paid to every agent.
+	BasicIncome float64
+}
+
+// GovernmentReport is what a Government collected and paid
+// over a tick, and what its treasury held at the end of it.
+type GovernmentReport struct {
+	Treasury     float64
+	IncomeTax    float64
+	SalesTax     float64
+	CorporateTax float64
+
+	// Uncollected is corporate tax owed
+	// by producers that could not pay it.
+	Uncollected float64
+
+	UnemploymentBenefits float64
+	BasicIncome          float64
+
+	// Shortfall is what transfers were cut by
+	// when the treasury could not pay them in full.
+	Shortfall float64
+}
+
+// Government taxes the economy and pays transfers out of
+// its treasury, which holds only what it has collected.
+// A nil Government levies and pays nothing.
+type Government struct {
+	Policy Policy
+
+	// Ledger, when set, records every tax and transfer.
+	Ledger *Ledger
+
+	treasury float64
+	revenue  map[string]float64
+	report   GovernmentReport
+	rwLock   sync.Mutex
+}
+
+// NewGovernment returns a Government with
+// an empty treasury carrying out policy.
+func NewGovernment(policy Policy) *Government {
+	return &Government{
+		Policy:  policy,
+		revenue: map[string]float64{},
+	}
+}
+
+// Treasury returns the cash the treasury holds.
+func (g *Government) Treasury() float64 {
+	if g == nil {
+		return 0
+	}
+	g.rwLock.Lock()
+	defer g.rwLock.Unlock()
+
+	return g.treasury
+}
+
+// Due returns the tax of kind owed on amount.
+func (g *Government) Due(kind Tax, amount float64) float64 {
+	if g == nil || amount <= 0 {
+		return 0
+	}
+
+	switch kind {
+	case TaxIncome:
+		return amount * g.Policy.IncomeTax
+	case TaxSales:
+		return amount * g.Policy.SalesTax
+	case TaxCorporate:
+		return amount * g.Policy.CorporateTax
+	}
+	return 0
+}
+
+// Collect pays tax of kind from the account from into the
+// treasury. The cash must already have been taken from it.
+func (g *Government) Collect(kind Tax, from string, tax float64, memo string) {
+	if g == nil || tax <= 0 {
+		return
+	}
+	g.rwLock.Lock()
+	defer g.rwLock.Unlock()
+
+	g.treasury += tax
+	switch kind {
+	case TaxIncome:
+		g.report.IncomeTax += tax
+	case TaxSales:
+		g.report.SalesTax += tax
+	case TaxCorporate:
+		g.report.CorporateTax += tax
+	}
+	g.Ledger.Transfer(from, AccountTreasury, tax, memo)
+}
+
+// LevyCorporate has every producer among agents pay
+// corporate tax on the revenue it made since last levied.
+func (g *Government) LevyCorporate(agents []*Agent) {
+	if g == nil {
+		return
+	}
+
+	for _, a := range agents {
+		if len(a.Producers) < 1 {
+			continue
+		}
+
+		a.rwLock.Lock()
+		revenue := a.Report.Revenue
+		a.rwLock.Unlock()
+
+		g.rwLock.Lock()
+		earned := revenue - g.revenue[a.Name]
+		g.revenue[a.Name] = revenue
+		g.rwLock.Unlock()
+
+		tax := g.Due(TaxCorporate, earned)
+		if tax <= 0 {
+			continue
+		}
+
+		memo := fmt.Sprintf("Corporate tax on %.2f", earned)
+		accepted := deliver(a.TransactionChannel, Transaction{
+			CashOut: tax,
+			Tax:     tax,
+			Memo:    memo,
+			From:    AccountTreasury,
+		})
+		if !accepted {
+			g.rwLock.Lock()
+			g.report.Uncollected += tax
+			g.rwLock.Unlock()
+			continue
+		}
+		g.Collect(TaxCorporate, a.Name, tax, memo)
+	}
+}
+
+// PayTransfers pays every agent its basic income and, when
+// it seeks work without a job, its unemployment benefit.
+// When the treasury cannot cover them all, every transfer
+// is cut by the same share.
+func (g *Government) PayTransfers(agents []*Agent) {
+	if g == nil {
+		return
+	}
+
+	benefits := make([]float64, len(agents))
+	owed := 0.0
+	for i, a := range agents {
+		a.rwLock.Lock()
+		if a.SeeksWage && !a.IsEmployed {
+			benefits[i] = g.Policy.UnemploymentBenefit
+		}
+		a.rwLock.Unlock()
+		owed += benefits[i] + g.Policy.BasicIncome
+	}
+	if owed <= 0 {
+		return
+	}
+
+	g.rwLock.Lock()
+	share := math.Min(1, g.treasury/owed)
+	g.report.Shortfall += owed * (1 - share)
+	g.rwLock.Unlock()
+
+	for i, a := range agents {
+		benefit := benefits[i] * share
+		income := g.Policy.BasicIncome * share
+		if benefit+income <= 0 {
+			continue
+		}
+
+		memo := "Transfer"
+		if benefit > 0 {
+			memo = "Unemployment benefit"
+		}
+		g.rwLock.Lock()
+		g.treasury -= benefit + income
+		g.report.UnemploymentBenefits += benefit
+		g.report.BasicIncome += income
+		g.rwLock.Unlock()
+
+		g.Ledger.Transfer(AccountTreasury, a.Name, benefit+income, memo)
+		deliver(a.TransactionChannel, Transaction{
+			CashIn: benefit + income,
+			Memo:   memo,
+			From:   AccountTreasury,
+		})
+	}
+}
+
+// Report returns what the government collected and paid
+// since it was last called.
+func (g *Government) Report() GovernmentReport {
+	if g == nil {
+		return GovernmentReport{}
+	}
+	g.rwLock.Lock()
+	defer g.rwLock.Unlock()
+
+	report := g.report
+	report.Treasury = g.treasury
+	g.report = GovernmentReport{}
+	return report
+}
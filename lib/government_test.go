@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGovernment(t *testing.T) {
+	s := newTestSimulation(Config{
+		Seed:          42,
+		Deterministic: true,
+		Policy: Policy{
+			IncomeTax:           .2,
+			SalesTax:            .1,
+			CorporateTax:        .05,
+			UnemploymentBenefit: 5,
+		},
+	})
+	defer s.Stop()
+
+	collected, paid := 0.0, 0.0
+	for i := 0; i < 10; i++ {
+		g := s.Step().Government
+		assert.NoError(t, s.CheckLedger())
+
+		assert.Greater(t, g.Treasury, -1e-9)
+		collected += g.IncomeTax + g.SalesTax + g.CorporateTax
+		paid += g.UnemploymentBenefits + g.BasicIncome
+	}
+	assert.Greater(t, collected, 0.0)
+	assert.Greater(t, paid, 0.0)
+	assert.InDelta(t, collected-paid, s.Government().Treasury(), 1e-6)
+
+	taxes, transfers := 0.0, 0.0
+	for _, a := range s.Agents() {
+		r := a.Record(s.Tick()).Report
+		taxes += r.TaxesPaid
+		transfers += r.Transfers
+	}
+	assert.InDelta(t, collected, taxes, 1e-6)
+	assert.InDelta(t, paid, transfers, 1e-6)
+}
+
+func TestPayTransfersCutsShortfall(t *testing.T) {
+	m := NewMarket()
+	l := NewLaborMarket()
+	g := NewGovernment(Policy{UnemploymentBenefit: 10, BasicIncome: 5})
+
+	agents := []*Agent{}
+	for _, seeksWage := range []bool{true, false} {
+		a := NewAgent(&m, &l)
+		a.SeeksWage = seeksWage
+		a.Start()
+		defer a.Quit()
+		agents = append(agents, &a)
+	}
+
+	// Nothing collected, nothing paid
+	g.PayTransfers(agents)
+	assert.Equal(t, 0.0, agents[0].Cash)
+	assert.Equal(t, 20.0, g.Report().Shortfall)
+
+	// Half of what is owed
+	g.Collect(TaxIncome, "worker", 10, "Income tax")
+	g.PayTransfers(agents)
+	assert.Equal(t, 7.5, agents[0].Cash)
+	assert.Equal(t, 2.5, agents[1].Cash)
+
+	r := g.Report()
+	assert.Equal(t, 10.0, r.IncomeTax)
+	assert.Equal(t, 5.0, r.UnemploymentBenefits)
+	assert.Equal(t, 5.0, r.BasicIncome)
+	assert.Equal(t, 10.0, r.Shortfall)
+	assert.Equal(t, 0.0, r.Treasury)
+}
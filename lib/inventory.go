@@ -8,7 +8,7 @@ type Inventory struct {
 	Originator         string
 	Price              float64
 	Cost               float64
-	Goods              []consumable.Consumable
+	Goods              consumable.Lots
 	Consumable         consumable.Consumable
 	TransactionChannel chan Transaction
 }
@@ -1,15 +1,32 @@
 package lib
 
 import (
+	"sort"
 	"sync"
 )
 
+// LaborContract is a worker's labor. While on the LaborMarket
+// Wage is the least the worker will take per production cycle;
+// once hired it is the wage agreed with Employer.
 type LaborContract struct {
-	Agent *Agent
+	Agent    *Agent
+	Employer *Agent
+	Wage     float64
+}
+
+// JobOffer is an employer's offer to hire one worker to
+// work the producer Key, at Wage per production cycle for
+// a worker of skill one and in proportion to skill for
+// any other.
+type JobOffer struct {
+	Employer *Agent
+	Wage     float64
+	Key      string
 }
 
 type LaborMarket struct {
 	labor  []LaborContract
+	offers []JobOffer
 	rwLock sync.Mutex
 	quit   chan bool
 	done   chan bool
@@ -31,6 +48,62 @@ func (m *LaborMarket) Append(contract LaborContract) {
 	m.labor = append(m.labor, contract)
 }
 
+// Offer posts a job offer until the next Match.
+func (m *LaborMarket) Offer(offer JobOffer) {
+	m.rwLock.Lock()
+	defer m.rwLock.Unlock()
+
+	m.offers = append(m.offers, offer)
+}
+
+// Match fills the highest offers first, each with the worker
+// asking the least per unit of skill at the offer's producer
+// whose ask the offer, scaled by that skill, covers. The wage
+// settles halfway between the two. Every hired worker is
+// handed to their employer, and every offer is withdrawn.
+func (m *LaborMarket) Match() []LaborContract {
+	m.rwLock.Lock()
+
+	offers := m.offers
+	m.offers = nil
+	sort.SliceStable(offers, func(i, j int) bool {
+		return offers[i].Wage > offers[j].Wage
+	})
+	sort.SliceStable(m.labor, func(i, j int) bool {
+		return m.labor[i].Wage < m.labor[j].Wage
+	})
+
+	hired := []LaborContract{}
+	for _, o := range offers {
+		best, cheapest, worth := -1, 0.0, 0.0
+		for i, l := range m.labor {
+			skill := l.Agent.Skill(o.Key)
+			if o.Wage*skill < l.Wage {
+				continue
+			}
+			if best < 0 || l.Wage/skill < cheapest {
+				best, cheapest, worth = i, l.Wage/skill, o.Wage*skill
+			}
+		}
+		if best < 0 {
+			continue
+		}
+
+		l := m.labor[best]
+		m.labor = append(m.labor[:best], m.labor[best+1:]...)
+
+		l.Employer = o.Employer
+		l.Wage = (worth + l.Wage) / 2
+		hired = append(hired, l)
+	}
+	m.rwLock.Unlock()
+
+	for _, l := range hired {
+		l.Employer.Hire(l)
+	}
+	return hired
+}
+
 func (m *LaborMarket) Shift() <-chan LaborContract {
 	c := make(chan LaborContract)
 	go func() {
@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLaborMarketMatch(t *testing.T) {
+	m := NewMarket()
+	l := NewLaborMarket()
+
+	agents := []*Agent{}
+	for _, name := range []string{"low", "high", "cheap", "dear", "mid"} {
+		a := NewAgent(&m, &l)
+		a.Name = name
+		go a.Start()
+		defer a.Quit()
+		agents = append(agents, &a)
+	}
+	low, high, cheap, dear, mid := agents[0], agents[1], agents[2], agents[3], agents[4]
+
+	l.Append(LaborContract{Agent: dear, Wage: 70})
+	l.Append(LaborContract{Agent: cheap, Wage: 30})
+	l.Append(LaborContract{Agent: mid, Wage: 50})
+	l.Offer(JobOffer{Employer: low, Wage: 40})
+	l.Offer(JobOffer{Employer: high, Wage: 60})
+
+	hired := l.Match()
+	if assert.Len(t, hired, 1) {
+		assert.Equal(t, cheap, hired[0].Agent)
+		assert.Equal(t, high, hired[0].Employer)
+		assert.Equal(t, 45.0, hired[0].Wage)
+	}
+	assert.Len(t, high.LaborContracts, 1)
+	assert.Empty(t, low.LaborContracts)
+	assert.True(t, cheap.IsEmployed)
+
+	// Offers are withdrawn by Match, the asks stay
+	assert.Empty(t, l.Match())
+	left := []*Agent{}
+	for c := range l.Read() {
+		left = append(left, c.Agent)
+	}
+	assert.Equal(t, []*Agent{mid, dear}, left)
+}
+
+func TestLaborMarketMatchesOnProductivity(t *testing.T) {
+	m := NewMarket()
+	l := NewLaborMarket()
+
+	employer := NewAgent(&m, &l)
+	employer.Name = "employer"
+	go employer.Start()
+	defer employer.Quit()
+
+	novice := NewAgent(&m, &l)
+	novice.Name = "novice"
+	expert := NewAgent(&m, &l)
+	expert.Name = "expert"
+	expert.Skills["orchard"] = 2
+	for _, a := range []*Agent{&novice, &expert} {
+		go a.Start()
+		defer a.Quit()
+	}
+
+	l.Append(LaborContract{Agent: &novice, Wage: 40})
+	l.Append(LaborContract{Agent: &expert, Wage: 50})
+	l.Offer(JobOffer{Employer: &employer, Wage: 45, Key: "orchard"})
+	l.Offer(JobOffer{Employer: &employer, Wage: 30, Key: "orchard"})
+
+	// The expert asks less for what they make, and is
+	// paid halfway between the ask and what they are
+	// worth to the employer. The novice is not worth 40.
+	hired := l.Match()
+	if assert.Len(t, hired, 1) {
+		assert.Equal(t, &expert, hired[0].Agent)
+		assert.Equal(t, (90+50)/2.0, hired[0].Wage)
+	}
+	assert.False(t, novice.IsEmployed)
+}
@@ -0,0 +1,205 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// AccountMarket holds cash between a buyer paying
+	// and the market paying the seller.
+	AccountMarket = "market"
+
+	// AccountEndowment is the source of the cash
+	// agents start out with.
+	AccountEndowment = "endowment"
+
+	productionPrefix = "production:"
+)
+
+// ProductionAccount returns the account production costs
+// for key are paid into. Cash paid into it and not paid
+// back out as wages leaves the economy.
+func ProductionAccount(key string) string {
+	return productionPrefix + key
+}
+
+// IsExternal reports whether account lies outside the
+// economy, as a source or a sink of money.
+func IsExternal(account string) bool {
+	return account == AccountEndowment || strings.HasPrefix(account, productionPrefix)
+}
+
+// Entry is one side of a transfer. Every transfer is
+// recorded as a debit to the account receiving the cash and
+// a matching credit to the account paying it.
+type Entry struct {
+	Transfer     int
+	Tick         int
+	Account      string
+	Counterparty string
+	Debit        float64
+	Credit       float64
+	Memo         string
+}
+
+// Ledger records every movement of cash as balanced
+// debit and credit entries. A nil Ledger records nothing.
+type Ledger struct {
+	entries   []Entry
+	balances  map[string]float64
+	transfers int
+	tick      int
+	rwLock    sync.Mutex
+}
+
+func NewLedger() *Ledger {
+	return &Ledger{
+		entries:  []Entry{},
+		balances: map[string]float64{},
+	}
+}
+
+// SetTick sets the tick new entries are recorded against.
+func (l *Ledger) SetTick(tick int) {
+	if l == nil {
+		return
+	}
+	l.rwLock.Lock()
+	defer l.rwLock.Unlock()
+
+	l.tick = tick
+}
+
+// Transfer records amount moving from one account to another.
+func (l *Ledger) Transfer(from string, to string, amount float64, memo string) {
+	if l == nil || amount == 0 {
+		return
+	}
+	l.rwLock.Lock()
+	defer l.rwLock.Unlock()
+
+	l.transfers++
+	l.entries = append(l.entries,
+		Entry{Transfer: l.transfers, Tick: l.tick, Account: from, Counterparty: to, Credit: amount, Memo: memo},
+		Entry{Transfer: l.transfers, Tick: l.tick, Account: to, Counterparty: from, Debit: amount, Memo: memo},
+	)
+	l.balances[from] -= amount
+	l.balances[to] += amount
+}
+
+// Balance returns the cash held by account: its debits less its credits.
+func (l *Ledger) Balance(account string) float64 {
+	if l == nil {
+		return 0
+	}
+	l.rwLock.Lock()
+	defer l.rwLock.Unlock()
+
+	return l.balances[account]
+}
+
+// Balances returns the balance of every account.
+func (l *Ledger) Balances() map[string]float64 {
+	balances := map[string]float64{}
+	if l == nil {
+		return balances
+	}
+	l.rwLock.Lock()
+	defer l.rwLock.Unlock()
+
+	for account, b := range l.balances {
+		balances[account] = b
+	}
+	return balances
+}
+
+// Entries returns every entry for which match returns true.
+func (l *Ledger) Entries(match func(Entry) bool) []Entry {
+	entries := []Entry{}
+	if l == nil {
+		return entries
+	}
+	l.rwLock.Lock()
+	defer l.rwLock.Unlock()
+
+	for _, e := range l.entries {
+		if match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// ByAccount returns the entries of account.
+func (l *Ledger) ByAccount(account string) []Entry {
+	return l.Entries(func(e Entry) bool {
+		return e.Account == account
+	})
+}
+
+// ByTick returns the entries recorded during tick.
+func (l *Ledger) ByTick(tick int) []Entry {
+	return l.Entries(func(e Entry) bool {
+		return e.Tick == tick
+	})
+}
+
+// ByCounterparty returns the entries of account
+// against counterparty.
+func (l *Ledger) ByCounterparty(account string, counterparty string) []Entry {
+	return l.Entries(func(e Entry) bool {
+		return e.Account == account && e.Counterparty == counterparty
+	})
+}
+
+// MoneySupply returns the cash inside the economy: everything
+// paid in by external sources less everything paid out to
+// external sinks.
+func (l *Ledger) MoneySupply() float64 {
+	supply := 0.0
+	for account, b := range l.Balances() {
+		if IsExternal(account) {
+			supply -= b
+		}
+	}
+	return supply
+}
+
+// Check compares the ledger against the cash actually held by
+// each internal account. It fails if any account holds a
+// different amount than the ledger says, or if the cash held
+// altogether differs from the money supply, which would mean
+// money was created or destroyed without being recorded.
+func (l *Ledger) Check(held map[string]float64) error {
+	const epsilon = 1e-6
+
+	balances := l.Balances()
+	problems := []string{}
+
+	accounts := []string{}
+	for account := range held {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	total := 0.0
+	for _, account := range accounts {
+		total += held[account]
+		if math.Abs(held[account]-balances[account]) > epsilon {
+			problems = append(problems, fmt.Sprintf("%s holds %.2f but the ledger says %.2f", account, held[account], balances[account]))
+		}
+	}
+
+	if supply := l.MoneySupply(); math.Abs(total-supply) > epsilon {
+		problems = append(problems, fmt.Sprintf("%.2f is held but the money supply is %.2f", total, supply))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("ledger: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLedger(t *testing.T) {
+	l := NewLedger()
+
+	l.SetTick(1)
+	l.Transfer(AccountEndowment, "a", 100, "Starting cash")
+	l.Transfer(AccountEndowment, "b", 50, "Starting cash")
+
+	l.SetTick(2)
+	l.Transfer("a", AccountMarket, 30, "Order 1")
+	l.Transfer(AccountMarket, "b", 30, "Order 1")
+	l.Transfer("b", ProductionAccount("orchard"), 20, "Cost")
+
+	assert.Equal(t, 70.0, l.Balance("a"))
+	assert.Equal(t, 60.0, l.Balance("b"))
+	assert.Equal(t, 0.0, l.Balance(AccountMarket))
+	assert.Equal(t, 130.0, l.MoneySupply())
+
+	assert.Len(t, l.ByTick(2), 6)
+	assert.Len(t, l.ByAccount("b"), 3)
+	if entries := l.ByCounterparty("b", AccountMarket); assert.Len(t, entries, 1) {
+		assert.Equal(t, 30.0, entries[0].Debit)
+	}
+
+	for _, e := range l.Entries(func(Entry) bool { return true }) {
+		assert.True(t, e.Debit == 0 || e.Credit == 0)
+	}
+
+	held := map[string]float64{"a": 70, "b": 60, AccountMarket: 0}
+	assert.NoError(t, l.Check(held))
+
+	// Cash that appears without a transfer breaks the invariant
+	held["a"] = 80
+	assert.Error(t, l.Check(held))
+}
@@ -2,6 +2,7 @@ package lib
 
 import (
 	"eco/lib/consumable"
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
@@ -14,30 +15,87 @@ import (
 type Transaction struct {
 	CashIn         float64
 	CashOut        float64
-	ConsumablesIn  []consumable.Consumable
-	ConsumablesOut []consumable.Consumable
+	ConsumablesIn  consumable.Lots
+	ConsumablesOut consumable.Lots
 
 	Employment *bool
+	Fill       *Fill
+
+	// Phase and Escrow place the transaction in a
+	// settlement. Failure says why one was rolled back.
+	Phase   Phase
+	Escrow  int
+	Failure string
+
+	// Spoiled is how many units of ConsumableKey
+	// the receiver had listed went off.
+	Spoiled int
+
+	// Tax is what the Government took of the
+	// transaction, withheld from CashIn or as CashOut.
+	Tax float64
+
+	// Capital marks CashIn paid for capital, such as a
+	// producer sold or a share of an estate, rather than
+	// earned by trade.
+	Capital bool
 
 	From             string
 	Memo             string
 	Time             time.Time
 	ConsumableKey    string
 	OrderIndex       int
+	OrderRef         int
 	AcceptChannel    chan bool
 	ResponseRequired bool
 }
 
-// Market coordinates transactions of goods
+// deliver sends t on c and blocks until the receiver has
+// processed it, returning whether it was accepted.
+func deliver(c chan Transaction, t Transaction) bool {
+	accepted, _ := deliverWithin(c, t, 0)
+	return accepted
+}
+
+// Market coordinates transactions of goods. For every
+// consumable it keeps a book of resting asks (inventories
+// in a heap by ascending price) and resting bids (orders
+// sorted by descending price), each in time priority
+// within a price. Incoming orders are matched against the
+// opposite side and trade at the resting order's price.
 type Market struct {
 	OrderChannel  chan Order
 	ReportChannel chan chan []string
 
-	inventoryMap map[string][]Inventory
+	// Synchronous makes Submit place orders on the calling
+	// goroutine rather than queueing them on OrderChannel,
+	// so that orders are matched in a fixed sequence.
+	Synchronous bool
+
+	// Ledger, when set, records the cash every fill moves.
+	Ledger *Ledger
+
+	// Government, when set, takes sales tax
+	// out of every sale.
+	Government *Government
+
+	// SettlementTimeout is how long the market waits on
+	// either side of a trade before rolling it back.
+	// Zero waits forever.
+	SettlementTimeout time.Duration
+
+	inventoryMap map[string]*askBook
+	bidMap       map[string][]Order
+	count        int
+	escrows      int
 	rwLock       sync.Mutex
 	quit         chan bool
 	done         chan bool
-	report       MarketReport
+	reports      map[string]MarketReport
+
+	// prices holds the average price
+	// each key last sold at.
+	prices map[string]float64
 }
 
 // NewMarket returns a new Markey
@@ -45,7 +103,10 @@ func NewMarket() Market {
 	return Market{
 		ReportChannel: make(chan chan []string),
 		OrderChannel:  make(chan Order, 100),
-		inventoryMap:  map[string][]Inventory{},
+		inventoryMap:  map[string]*askBook{},
+		bidMap:        map[string][]Order{},
+		reports:       map[string]MarketReport{},
+		prices:        map[string]float64{},
 		rwLock:        sync.Mutex{},
 		quit:          make(chan bool),
 	}
@@ -63,116 +124,457 @@ func (m *Market) Start() {
 
 // ProcessOrders processes orders
 func (m *Market) ProcessOrders() {
-	count := 0
 	for {
 		select {
 		case returnChan := <-m.ReportChannel:
 			returnChan <- m.Report()
 			continue
 		case order := <-m.OrderChannel:
-			count++
-
-			order.Index = count
-
-			name := order.From
-			quantity := order.Quantity
-			key := order.Consumable.Key()
-
-			fmtDebug("Order %d: Market received order from %s for %d %s. %.2f\n", count, name, quantity, key, order.Cash)
-
-			// Check to see if they can even afford one unit at the lowest price
-			func() {
-				lowest := <-m.ReadLowest(key)
-				if len(lowest.Goods) == 0 {
-					// Can't fill this order
-					fmtDebug("\tOrder %d: Market has no inventory of %s for %s.\n", count, key, name)
-					return
-				}
-
-				if order.Cash < lowest.Price {
-					fmtDebug("\tOrder %d: %s couldn't afford any units of %s at %.2f. (%.2f, 1)\n", count, name, key, lowest.Price, order.Cash)
-					return
-				}
-
-				if q := order.PurchasableQuantity(lowest); q < 1 {
-					fmtDebug("\tOrder %d: %s couldn't afford any units of %s at %.2f. (%.2f, 2)\n", count, name, key, lowest.Price, order.Cash)
-					return
-				} else {
-					quantity = q
-				}
-
-				// At this point we want to purchase it
-				// Do so synchronously
-				// Give the purchaser a chance to decline
-				invChan, confirm := m.PopNConfirm(key, quantity)
-				inv := <-invChan
-				if len(inv.Goods) == 0 {
-					fmtDebug("\tOrder %d: no supply\n", count)
-					confirm <- false
-					return
-				}
-
-				t := Transaction{
-					ConsumableKey:    key,
-					ConsumablesIn:    inv.Goods,
-					CashOut:          float64(quantity) * inv.Price,
-					From:             inv.Originator,
-					OrderIndex:       order.Index,
-					AcceptChannel:    make(chan bool),
-					ResponseRequired: true,
-				}
-
-				go func() { order.FulfillmentChannel <- t }()
-				accepted := <-t.AcceptChannel
-				if !accepted {
-					fmtDebug("\tOrder %d: not accepted\n", count)
-					confirm <- false
-					return
-				}
-
-				confirm <- true
-
-				price := float64(quantity) * inv.Price
-
-				m.report.ProductSold += quantity
-				m.report.TotalCashFlow += price
-
-				// Send money to originator
-				inv.TransactionChannel <- Transaction{
-					CashIn:     price,
-					From:       order.From,
-					OrderIndex: order.Index,
-				}
-				fmtDebug("\tOrder %d: order filled %d\n", order.Index, len(inv.Goods))
-			}()
+			m.PlaceOrder(order)
 		case <-m.quit:
 			return
 		}
 	}
 }
 
-// Push appends the inventory to the slice at key
+// Submit hands the order to the market.
+func (m *Market) Submit(order Order) {
+	if m.Synchronous {
+		m.PlaceOrder(order)
+		return
+	}
+	m.OrderChannel <- order
+}
+
+// PlaceOrder matches the order against the opposite side of
+// the book and rests whatever remains of a limit order.
+func (m *Market) PlaceOrder(order Order) {
+	if order.Side == Ask {
+		m.Push(order.Consumable.Key(), Inventory{
+			Originator:         order.From,
+			Price:              order.Price,
+			Goods:              order.Goods,
+			Consumable:         order.Consumable,
+			TransactionChannel: order.FulfillmentChannel,
+		})
+		return
+	}
+
+	m.rwLock.Lock()
+	m.count++
+	order.Index = m.count
+
+	name := order.From
+	key := order.Consumable.Key()
+
+	fmtDebug("Order %d: Market received bid from %s for %d %s at %.2f. %.2f\n", order.Index, name, order.Quantity, key, order.Price, order.Cash)
+
+	// A new bid replaces whatever the buyer had resting,
+	// so a bid for nothing cancels it
+	m.cancelBids(key, name)
+
+	// Sweep the asks from the lowest price up, buying
+	// from as many sellers as it takes
+	fill := Fill{OrderIndex: order.Index, OrderRef: order.Ref, Key: key}
+	accepted := true
+	book := m.book(key)
+	for order.Quantity > 0 {
+		lowest, ok := book.Lowest()
+		if !ok {
+			fmtDebug("\tOrder %d: Market has no inventory of %s for %s.\n", order.Index, key, name)
+			break
+		}
+
+		if !order.Crosses(lowest.Price) {
+			fmtDebug("\tOrder %d: %s bid %.2f for %s but the lowest ask is %.2f.\n", order.Index, name, order.Price, key, lowest.Price)
+			break
+		}
+
+		quantity := order.PurchasableQuantity(lowest.Inventory)
+		if quantity < 1 {
+			fmtDebug("\tOrder %d: %s couldn't afford any units of %s at %.2f. (%.2f)\n", order.Index, name, key, lowest.Price, order.Cash)
+			break
+		}
+
+		// The goods come off the book into escrow, and what
+		// is left keeps its place at the front
+		book.PopLowest()
+		sold, rest := lowest.Goods.Take(quantity)
+		lowest.Goods = rest
+		book.Relist(lowest)
+
+		e := m.escrow(order, lowest.Inventory, sold, lowest.Price)
+		m.rwLock.Unlock()
+		err := m.settle(e)
+		m.rwLock.Lock()
+
+		if sellerFailed(err) {
+			// The order moves on to the next ask
+			pulled := sold.Count() + m.pull(key, lowest.Originator)
+			log(fmt.Sprintf("Order %d: %s did not settle, %d %s pulled: %v", order.Index, lowest.Originator, pulled, key, err))
+			continue
+		}
+		if err != nil {
+			fmtDebug("\tOrder %d: not accepted: %v\n", order.Index, err)
+			lowest.Goods = sold
+			book.Relist(lowest)
+			accepted = false
+			break
+		}
+
+		m.reportSale(key, quantity, e.Total())
+		fill.Add(lowest.Originator, quantity, lowest.Price)
+		order.Quantity -= quantity
+		order.Cash -= e.Total()
+	}
+
+	if accepted && order.Quantity > 0 && order.Price > 0 && order.Cash >= order.Price {
+		m.restBid(key, order)
+		fill.Resting = order.Quantity
+		fmtDebug("\tOrder %d: %d %s resting at %.2f\n", order.Index, order.Quantity, key, order.Price)
+	}
+	m.rwLock.Unlock()
+
+	// Confirmed even when nothing traded, so the
+	// buyer knows whether its bid rests
+	m.confirm(order, fill)
+}
+
+// Push matches the inventory against resting bids for key
+// and rests whatever remains as an ask.
 func (m *Market) Push(key string, inv Inventory) {
 	m.rwLock.Lock()
 	defer m.rwLock.Unlock()
 
-	inventories, ok := m.inventoryMap[key]
+	r := m.reports[key]
+	r.ProductReceived += inv.Goods.Count()
+	m.reports[key] = r
+
+	m.push(key, inv)
+}
+
+// push is Push for inventory already counted as received.
+// The caller must hold m.rwLock.
+func (m *Market) push(key string, inv Inventory) {
+	for len(inv.Goods) > 0 {
+		bids := m.bidMap[key]
+		if len(bids) < 1 || bids[0].Price < inv.Price {
+			break
+		}
+
+		// The bid comes off the book while it settles
+		highest := bids[0]
+		m.bidMap[key] = bids[1:]
+
+		// Trades print at the resting bid's price
+		at := inv
+		at.Price = highest.Price
+		quantity := highest.PurchasableQuantity(at)
+		if quantity < 1 {
+			continue
+		}
+
+		sold, rest := inv.Goods.Take(quantity)
+		e := m.escrow(highest, inv, sold, highest.Price)
+		m.rwLock.Unlock()
+		err := m.settle(e)
+		m.rwLock.Lock()
+
+		if sellerFailed(err) {
+			// The bid keeps its place, and none of
+			// the seller's goods stay on the market
+			m.returnBid(key, highest)
+			pulled := inv.Goods.Count() + m.pull(key, inv.Originator)
+			log(fmt.Sprintf("Order %d: %s did not settle, %d %s pulled: %v", highest.Index, inv.Originator, pulled, key, err))
+			return
+		}
+		if err != nil {
+			// The bidder can no longer pay, drop the bid
+			fmtDebug("\tOrder %d: dropped: %v\n", highest.Index, err)
+			continue
+		}
+
+		m.reportSale(key, quantity, e.Total())
+		inv.Goods = rest
+		highest.Quantity -= quantity
+		highest.Cash -= e.Total()
+
+		fill := Fill{OrderIndex: highest.Index, OrderRef: highest.Ref, Key: key}
+		fill.Add(inv.Originator, quantity, highest.Price)
+		if highest.Quantity > 0 && highest.Cash >= highest.Price && m.returnBid(key, highest) {
+			fill.Resting = highest.Quantity
+		}
+
+		m.rwLock.Unlock()
+		m.confirm(highest, fill)
+		m.rwLock.Lock()
+	}
+
+	if len(inv.Goods) == 0 {
+		return
+	}
+
+	m.book(key).List(inv)
+}
+
+// book returns the asks resting at key.
+// The caller must hold m.rwLock.
+func (m *Market) book(key string) *askBook {
+	b, ok := m.inventoryMap[key]
 	if !ok {
-		m.inventoryMap[key] = []Inventory{inv}
+		b = &askBook{}
+		m.inventoryMap[key] = b
+	}
+	return b
+}
+
+// sellerFailed reports whether err is a
+// settlement that failed on the seller's side.
+func sellerFailed(err error) bool {
+	var s *SettlementError
+	return errors.As(err, &s) && s.Side == Ask
+}
+
+// pull takes every listing of originator at key off the
+// book, and returns how many units it held. A seller that
+// failed to settle would fail every buyer after it.
+// The caller must hold m.rwLock.
+func (m *Market) pull(key string, originator string) int {
+	pulled := 0
+	for _, l := range m.book(key).Remove(func(inv Inventory) bool {
+		return inv.Originator == originator
+	}) {
+		pulled += l.Goods.Count()
+	}
+	return pulled
+}
+
+// escrow opens an escrow for goods taken from inv and
+// sold to order at price. The caller must hold m.rwLock.
+func (m *Market) escrow(order Order, inv Inventory, goods consumable.Lots, price float64) Escrow {
+	m.escrows++
+	return Escrow{
+		ID:     m.escrows,
+		Order:  order,
+		Seller: inv,
+		Goods:  goods,
+		Price:  price,
+	}
+}
+
+// reportSale adds a settled sale to the report of key.
+// The caller must hold m.rwLock.
+func (m *Market) reportSale(key string, quantity int, total float64) {
+	r := m.reports[key]
+	r.ProductSold += quantity
+	r.TotalCashFlow += total
+	m.reports[key] = r
+	if quantity > 0 {
+		m.prices[key] = total / float64(quantity)
+	}
+}
+
+// LastPrice returns the average price key last sold at,
+// and false when it has never sold.
+func (m *Market) LastPrice(key string) (float64, bool) {
+	m.rwLock.Lock()
+	defer m.rwLock.Unlock()
+
+	price, ok := m.prices[key]
+	return price, ok
+}
+
+// confirm reports the fill back to whoever placed the order.
+// The caller must not hold m.rwLock.
+func (m *Market) confirm(order Order, fill Fill) {
+	if _, err := deliverWithin(order.FulfillmentChannel, Transaction{
+		Fill:          &fill,
+		ConsumableKey: fill.Key,
+		OrderIndex:    order.Index,
+		OrderRef:      order.Ref,
+	}, m.SettlementTimeout); err != nil {
+		log(fmt.Sprintf("Order %d: %s did not take its fill: %v", order.Index, order.From, err))
 		return
 	}
+	fmtDebug("\tOrder %d: %s filled %d %s from %d sellers at an average of %.2f\n", order.Index, order.From, fill.Quantity, fill.Key, len(fill.Sellers), fill.AveragePrice())
+}
 
-	inventories = append(inventories, inv)
+// restBid inserts the order into the bids at key behind
+// every bid at the same or a better price.
+// The caller must hold m.rwLock.
+func (m *Market) restBid(key string, order Order) {
+	bids := m.bidMap[key]
+	i := sort.Search(len(bids), func(i int) bool {
+		return bids[i].Price < order.Price
+	})
 
-	m.report.ProductReceived += len(inv.Goods)
+	bids = append(bids, Order{})
+	copy(bids[i+1:], bids[i:])
+	bids[i] = order
+	m.bidMap[key] = bids
+}
 
-	// Sort the inventories by price
-	// TODO: This is potentially expensive
-	sort.Slice(inventories, func(i, j int) bool {
-		return inventories[i].Price < inventories[j].Price
+// returnBid puts a partly filled bid back ahead of every
+// bid at its price, unless its buyer has bid again since.
+// It reports whether the bid was put back.
+// The caller must hold m.rwLock.
+func (m *Market) returnBid(key string, order Order) bool {
+	bids := m.bidMap[key]
+	for _, b := range bids {
+		if b.From == order.From {
+			return false
+		}
+	}
+
+	i := sort.Search(len(bids), func(i int) bool {
+		return bids[i].Price <= order.Price
 	})
 
-	m.inventoryMap[key] = inventories
+	bids = append(bids, Order{})
+	copy(bids[i+1:], bids[i:])
+	bids[i] = order
+	m.bidMap[key] = bids
+	return true
+}
+
+// cancelBids removes every resting bid at key from name.
+// The caller must hold m.rwLock.
+func (m *Market) cancelBids(key string, name string) {
+	bids := m.bidMap[key]
+	kept := bids[:0]
+	for _, b := range bids {
+		if b.From != name {
+			kept = append(kept, b)
+		}
+	}
+	m.bidMap[key] = kept
+}
+
+// CancelBids removes every bid name has resting.
+func (m *Market) CancelBids(name string) {
+	m.rwLock.Lock()
+	defer m.rwLock.Unlock()
+
+	for key := range m.bidMap {
+		m.cancelBids(key, name)
+	}
+}
+
+// Spoil removes every unit that has expired by tick from
+// the listings and tells each seller how many of theirs
+// went off.
+func (m *Market) Spoil(tick int) {
+	m.rwLock.Lock()
+
+	keys := []string{}
+	for key := range m.inventoryMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Sellers are told once the lock is released, so
+	// one that does not answer holds up no one else
+	type notice struct {
+		c    chan Transaction
+		t    Transaction
+		from string
+	}
+	notices := []notice{}
+	for _, key := range keys {
+		m.inventoryMap[key].Filter(func(inv *Inventory) bool {
+			fresh, spoiled := inv.Goods.Expire(tick)
+			if spoiled > 0 {
+				r := m.reports[key]
+				r.Spoiled += spoiled
+				m.reports[key] = r
+
+				notices = append(notices, notice{c: inv.TransactionChannel, from: inv.Originator, t: Transaction{
+					Spoiled:       spoiled,
+					ConsumableKey: key,
+					Memo:          fmt.Sprintf("%d %s spoiled on the market", spoiled, key),
+				}})
+				fmtDebug("%d %s listed by %s spoiled.\n", spoiled, key, inv.Originator)
+			}
+
+			inv.Goods = fresh
+			return len(fresh) > 0
+		})
+	}
+	m.rwLock.Unlock()
+
+	for _, n := range notices {
+		if _, err := deliverWithin(n.c, n.t, m.SettlementTimeout); err != nil {
+			log(fmt.Sprintf("%s did not hear that %d %s spoiled: %v", n.from, n.t.Spoiled, n.t.ConsumableKey, err))
+		}
+	}
+}
+
+// Listed returns how many units originator has resting
+// on the market across every key.
+func (m *Market) Listed(originator string) int {
+	m.rwLock.Lock()
+	defer m.rwLock.Unlock()
+
+	listed := 0
+	for key := range m.inventoryMap {
+		listed += m.listedOf(originator, key)
+	}
+	return listed
+}
+
+// ListedOf returns how many units of key
+// originator has resting on the market.
+func (m *Market) ListedOf(originator string, key string) int {
+	m.rwLock.Lock()
+	defer m.rwLock.Unlock()
+
+	return m.listedOf(originator, key)
+}
+
+// listedOf is ListedOf. The caller must hold m.rwLock.
+func (m *Market) listedOf(originator string, key string) int {
+	listed := 0
+	m.inventoryMap[key].Each(func(inv Inventory) {
+		if inv.Originator == originator {
+			listed += inv.Goods.Count()
+		}
+	})
+	return listed
+}
+
+// Reprice asks price for a new asking price for each of
+// originator's listings at key. A listing whose price
+// changes goes behind every listing at its new price, and
+// trades with any resting bid it now crosses.
+func (m *Market) Reprice(originator string, key string, price func(Inventory) float64) {
+	m.rwLock.Lock()
+	defer m.rwLock.Unlock()
+
+	book := m.inventoryMap[key]
+	listings := book.Remove(func(inv Inventory) bool {
+		return inv.Originator == originator
+	})
+
+	for _, l := range listings {
+		p := price(l.Inventory)
+		if p == l.Price {
+			book.Relist(l)
+			continue
+		}
+
+		fmtDebug("%s repriced %d %s from %.2f to %.2f.\n", originator, l.Goods.Count(), key, l.Price, p)
+		l.Price = p
+		m.push(key, l.Inventory)
+	}
+}
+
+// Bids returns a copy of the bids resting at key,
+// highest price first.
+func (m *Market) Bids(key string) []Order {
+	m.rwLock.Lock()
+	defer m.rwLock.Unlock()
+
+	return append([]Order{}, m.bidMap[key]...)
 }
 
 // ReadLowest returns a channel that returns the lowest priced
@@ -183,14 +585,14 @@ func (m *Market) ReadLowest(key string) <-chan Inventory {
 		m.rwLock.Lock()
 		defer m.rwLock.Unlock()
 
-		inventories, ok := m.inventoryMap[key]
-		if !ok || len(inventories) < 1 {
+		lowest, ok := m.inventoryMap[key].Lowest()
+		if !ok {
 			go func() { c <- Inventory{} }()
 			return
 		}
 
 		defer close(c)
-		c <- inventories[0]
+		c <- lowest.Inventory
 	}()
 
 	return c
@@ -202,104 +604,117 @@ func (m *Market) Pop(key string) <-chan Inventory {
 		m.rwLock.Lock()
 		defer m.rwLock.Unlock()
 
-		var inventory Inventory
-		inventories, ok := m.inventoryMap[key]
-		if !ok || len(inventories) < 1 {
+		lowest, ok := m.inventoryMap[key].PopLowest()
+		if !ok {
 			go func() { c <- Inventory{} }()
 			return
 		}
 
-		inventory, inventories = inventories[0], inventories[1:]
-		m.inventoryMap[key] = inventories
-
 		defer close(c)
-		c <- inventory
+		c <- lowest.Inventory
 	}()
 
 	return c
 }
 
+// PopNConfirm takes up to quantity units off the lowest
+// ask at key and sends them on the returned channel. They
+// are held out of the book until confirmed, and put back
+// if refused or not confirmed within SettlementTimeout.
+// The channel is closed once the book is settled.
 func (m *Market) PopNConfirm(key string, quantity int) (<-chan Inventory, chan bool) {
 	c := make(chan Inventory)
 	confirm := make(chan bool)
 	go func() {
-		m.rwLock.Lock()
-		defer m.rwLock.Unlock()
 		defer close(c)
 
-		var inventory Inventory
-		inventories, ok := m.inventoryMap[key]
-		if !ok || len(inventories) < 1 {
-			go func() { c <- Inventory{} }()
+		m.rwLock.Lock()
+		book := m.inventoryMap[key]
+		lowest, ok := book.PopLowest()
+		m.rwLock.Unlock()
+		if !ok {
+			c <- Inventory{}
 			return
 		}
 
-		inventory, inventories = inventories[0], inventories[1:]
+		taken, rest := lowest.Goods.Take(quantity)
+		i := lowest.Inventory
+		i.Goods = taken
+		c <- i
 
-		go func(i Inventory, quantity int) {
-			i.Goods = i.Goods[0:quantity]
-			c <- i
-		}(inventory, quantity)
-
-		if <-confirm {
-			if len(inventory.Goods) != quantity {
-				// Replace the inventory minus what was purchased
-				inventory.Goods = inventory.Goods[quantity:]
-				inventories = append(inventories, inventory)
-				m.inventoryMap[key] = inventories
-				return
-			}
-			m.inventoryMap[key] = inventories
+		if m.awaitConfirm(confirm) {
+			// Put back what was not purchased
+			// at its place in the book
+			lowest.Goods = rest
 		}
+
+		m.rwLock.Lock()
+		book.Relist(lowest)
+		m.rwLock.Unlock()
 	}()
 
 	return c, confirm
 }
 
+// PopConfirm is PopNConfirm for the whole of the lowest ask.
 func (m *Market) PopConfirm(key string) (<-chan Inventory, chan bool) {
 	c := make(chan Inventory)
 	confirm := make(chan bool)
 	go func() {
-		m.rwLock.Lock()
-		defer m.rwLock.Unlock()
 		defer close(c)
 
-		var inventory Inventory
-		inventories, ok := m.inventoryMap[key]
-		if !ok || len(inventories) < 1 {
-			go func() { c <- Inventory{} }()
+		m.rwLock.Lock()
+		book := m.inventoryMap[key]
+		lowest, ok := book.PopLowest()
+		m.rwLock.Unlock()
+		if !ok {
+			c <- Inventory{}
 			return
 		}
 
-		inventory, inventories = inventories[0], inventories[1:]
+		c <- lowest.Inventory
 
-		go func() {
-			c <- inventory
-		}()
-
-		if <-confirm {
-			m.inventoryMap[key] = inventories
+		if !m.awaitConfirm(confirm) {
+			m.rwLock.Lock()
+			book.Relist(lowest)
+			m.rwLock.Unlock()
 		}
 	}()
 
 	return c, confirm
 }
 
-// Read returns a channel that returns all inventories in the slice at key
+// awaitConfirm waits for an answer on confirm, counting
+// no answer within SettlementTimeout as a refusal.
+func (m *Market) awaitConfirm(confirm chan bool) bool {
+	if m.SettlementTimeout <= 0 {
+		return <-confirm
+	}
+
+	select {
+	case ok := <-confirm:
+		return ok
+	case <-time.After(m.SettlementTimeout):
+		return false
+	}
+}
+
+// Read returns a channel that returns all inventories
+// at key, lowest price first
 func (m *Market) Read(key string) <-chan Inventory {
 	c := make(chan Inventory, 1)
 	go func() {
 		m.rwLock.Lock()
 		defer m.rwLock.Unlock()
 
-		inventories, ok := m.inventoryMap[key]
+		book, ok := m.inventoryMap[key]
 		if !ok {
 			go func() { c <- Inventory{} }()
 			return
 		}
 
 		defer close(c)
-		for _, i := range inventories {
+		for _, i := range book.Sorted() {
 			c <- i
 		}
 	}()
@@ -307,11 +722,45 @@ func (m *Market) Read(key string) <-chan Inventory {
 	return c
 }
 
+// MarketReport returns the activity across every key since
+// the last report and starts a new one.
 func (m *Market) MarketReport() MarketReport {
+	total := MarketReport{}
+	for _, r := range m.MarketReports() {
+		total.Add(r)
+	}
+	return total
+}
+
+// MarketReports returns the activity of each key since
+// the last report and starts a new one.
+func (m *Market) MarketReports() map[string]MarketReport {
+	m.rwLock.Lock()
+	defer m.rwLock.Unlock()
 	defer func() {
-		m.report = MarketReport{}
+		m.reports = map[string]MarketReport{}
 	}()
-	return m.report
+
+	reports := map[string]MarketReport{}
+	for key, r := range m.reports {
+		reports[key] = r
+	}
+	for key, book := range m.inventoryMap {
+		r := reports[key]
+		book.Each(func(inv Inventory) {
+			r.Stock += inv.Goods.Count()
+		})
+		reports[key] = r
+	}
+
+	for key, r := range reports {
+		r.Key = key
+		if r.ProductSold > 0 {
+			r.AveragePrice = r.TotalCashFlow / float64(r.ProductSold)
+		}
+		reports[key] = r
+	}
+	return reports
 }
 
 func (m *Market) Report() []string {
@@ -320,22 +769,27 @@ func (m *Market) Report() []string {
 		m.rwLock.Unlock()
 	}()
 
+	report := MarketReport{}
+	for _, r := range m.reports {
+		report.Add(r)
+	}
+
 	stock := 0
-	for _, inventories := range m.inventoryMap {
-		for _, inv := range inventories {
-			stock += len(inv.Goods)
-		}
+	for _, book := range m.inventoryMap {
+		book.Each(func(inv Inventory) {
+			stock += inv.Goods.Count()
+		})
 	}
 	log(stock)
 
 	avg := 0.0
-	if m.report.TotalCashFlow > 0.0 {
-		avg = m.report.TotalCashFlow / float64(m.report.ProductSold)
+	if report.TotalCashFlow > 0.0 {
+		avg = report.TotalCashFlow / float64(report.ProductSold)
 	}
 	return []string{
-		fmt.Sprintf("%d", m.report.ProductSold),
-		fmt.Sprintf("%d", m.report.ProductReceived),
-		fmt.Sprintf("%.2f", m.report.TotalCashFlow),
+		fmt.Sprintf("%d", report.ProductSold),
+		fmt.Sprintf("%d", report.ProductReceived),
+		fmt.Sprintf("%.2f", report.TotalCashFlow),
 		fmt.Sprintf("%.2f", avg),
 		fmt.Sprintf("%d", stock),
 	}
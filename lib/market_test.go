@@ -1,85 +1,735 @@
 package lib
 
 import (
-	"eco/consumable"
+	"eco/lib/consumable"
 	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
 	"sync"
 	"testing"
+	"time"
 )
 
-func TestWrite(t *testing.T) {
-	// Setup a Market2 with an InventoryMap
-	m := NewMarket()
+func apples(n int) consumable.Lots {
+	return consumable.Lots{consumable.NewLot(consumable.NewApple(), n)}
+}
 
-	expectedInventory := Inventory{
-		Supply: consumable.Supply{
-			Consumable: consumable.NewApple(),
-			Quantity:   10,
-			Price:      0.25,
-		},
-	}
+// trader accepts every transaction sent on its channel,
+// unless it refuses the phase, and keeps a copy of each
+// trade and fill.
+type trader struct {
+	channel      chan Transaction
+	refuse       Phase
+	slow         Phase
+	delay        time.Duration
+	transactions []Transaction
+	fills        []Fill
+	rwLock       sync.Mutex
+}
 
-	inventories := []Inventory{}
-	for i := 0; i < 100; i++ {
-		e := expectedInventory
-		e.Supply.Quantity += i
-		inventories = append(inventories, e)
+func newTrader() *trader {
+	return startTrader(PhaseNone)
+}
+
+// newRefuser returns a trader that never
+// puts cash into escrow.
+func newRefuser() *trader {
+	return startTrader(PhaseReserve)
+}
+
+// startTrader starts a trader refusing every transaction
+// in phase refuse. PhaseNone refuses nothing.
+func startTrader(refuse Phase) *trader {
+	return (&trader{channel: make(chan Transaction), refuse: refuse}).start()
+}
+
+// newSlowTrader returns a trader that takes delay
+// to answer every transaction in phase slow.
+func newSlowTrader(slow Phase, delay time.Duration) *trader {
+	return (&trader{channel: make(chan Transaction), slow: slow, delay: delay}).start()
+}
+
+func (b *trader) start() *trader {
+	go func() {
+		for t := range b.channel {
+			b.rwLock.Lock()
+			if t.Fill != nil {
+				b.fills = append(b.fills, *t.Fill)
+			} else {
+				b.transactions = append(b.transactions, t)
+			}
+			b.rwLock.Unlock()
+			if b.slow != PhaseNone && t.Phase == b.slow {
+				time.Sleep(b.delay)
+			}
+			if t.ResponseRequired {
+				t.AcceptChannel <- b.refuse == PhaseNone || t.Phase != b.refuse
+			}
+		}
+	}()
+	return b
+}
+
+func (b *trader) Transactions() []Transaction {
+	b.rwLock.Lock()
+	defer b.rwLock.Unlock()
+	return append([]Transaction{}, b.transactions...)
+}
+
+// Phase returns the transactions in phase p.
+func (b *trader) Phase(p Phase) []Transaction {
+	b.rwLock.Lock()
+	defer b.rwLock.Unlock()
+
+	transactions := []Transaction{}
+	for _, t := range b.transactions {
+		if t.Phase == p {
+			transactions = append(transactions, t)
+		}
 	}
+	return transactions
+}
 
-	m.inventoryMap[consumable.KeyApple] = inventories
+func (b *trader) Fills() []Fill {
+	b.rwLock.Lock()
+	defer b.rwLock.Unlock()
+	return append([]Fill{}, b.fills...)
+}
+
+func TestPush(t *testing.T) {
+	m := NewMarket()
+
+	for _, price := range []float64{3, 1, 2, 1} {
+		m.Push(consumable.KeyApple, Inventory{
+			Price:      price,
+			Goods:      apples(10),
+			Consumable: consumable.NewApple(),
+		})
+	}
 
-	m.Write(consumable.KeyApple, []Inventory{expectedInventory})
-	rc := m.Read(consumable.KeyApple)
-	for inv := range rc {
-		assert.Equal(t, expectedInventory, inv)
+	prices := []float64{}
+	for inv := range m.Read(consumable.KeyApple) {
+		prices = append(prices, inv.Price)
 	}
+	assert.Equal(t, []float64{1, 1, 2, 3}, prices)
 }
 
 func TestRead(t *testing.T) {
-	// Setup a Market2 with an InventoryMap
 	m := NewMarket()
 
 	expectedInventory := Inventory{
-		Supply: consumable.Supply{
-			Consumable: consumable.NewApple(),
-			Quantity:   10,
-			Price:      0.25,
-		},
+		Price:      0.25,
+		Consumable: consumable.NewApple(),
 	}
 
-	inventories := []Inventory{}
+	// Listings at one price come back in the order listed
+	book := &askBook{}
 	for i := 0; i < 100; i++ {
 		e := expectedInventory
-		e.Supply.Quantity += i
-		inventories = append(inventories, e)
+		e.Cost += float64(i)
+		book.List(e)
 	}
 
-	m.inventoryMap[consumable.KeyApple] = inventories
+	m.inventoryMap[consumable.KeyApple] = book
 
 	wg := sync.WaitGroup{}
 	wg.Add(2)
-	go func() {
-		counter := 0
-		rc := m.Read(consumable.KeyApple)
-		for inv := range rc {
-			e := expectedInventory
-			e.Supply.Quantity += counter
-			assert.Equal(t, e, inv)
-			counter++
-		}
-		wg.Done()
-	}()
+	for r := 0; r < 2; r++ {
+		go func() {
+			counter := 0
+			rc := m.Read(consumable.KeyApple)
+			for inv := range rc {
+				e := expectedInventory
+				e.Cost += float64(counter)
+				assert.Equal(t, e, inv)
+				counter++
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+}
 
-	go func() {
-		counter := 0
-		rc := m.Read(consumable.KeyApple)
-		for inv := range rc {
-			e := expectedInventory
-			e.Supply.Quantity += counter
-			assert.Equal(t, e, inv)
-			counter++
+func TestBidTradesAtRestingAskPrice(t *testing.T) {
+	m := NewMarket()
+	seller := newTrader()
+
+	for _, price := range []float64{2, 1} {
+		m.Push(consumable.KeyApple, Inventory{
+			Originator:         "seller",
+			Price:              price,
+			Goods:              apples(10),
+			Consumable:         consumable.NewApple(),
+			TransactionChannel: seller.channel,
+		})
+	}
+
+	b := newTrader()
+	m.PlaceOrder(Order{
+		From:               "buyer",
+		Side:               Bid,
+		Quantity:           15,
+		Price:              3,
+		Cash:               100,
+		Consumable:         consumable.NewApple(),
+		FulfillmentChannel: b.channel,
+	})
+
+	if reserved := b.Phase(PhaseReserve); assert.Len(t, reserved, 2) {
+		assert.Equal(t, 10.0, reserved[0].CashOut)
+		assert.Equal(t, 10.0, reserved[1].CashOut)
+	}
+	if delivered := b.Phase(PhaseCommit); assert.Len(t, delivered, 2) {
+		assert.Equal(t, 10, delivered[0].ConsumablesIn.Count())
+		assert.Equal(t, 5, delivered[1].ConsumablesIn.Count())
+	}
+	if paid := seller.Phase(PhaseCommit); assert.Len(t, paid, 2) {
+		assert.Equal(t, 10.0, paid[0].CashIn)
+		assert.Equal(t, 10.0, paid[1].CashIn)
+	}
+
+	if summary := b.Fills(); assert.Len(t, summary, 1) {
+		assert.Equal(t, 15, summary[0].Quantity)
+		assert.InDelta(t, 20.0/15.0, summary[0].AveragePrice(), 1e-9)
+	}
+
+	// The last trade took the dearer ask
+	price, ok := m.LastPrice(consumable.KeyApple)
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, price)
+
+	remaining := []Inventory{}
+	for inv := range m.Read(consumable.KeyApple) {
+		remaining = append(remaining, inv)
+	}
+	if assert.Len(t, remaining, 1) {
+		assert.Equal(t, 2.0, remaining[0].Price)
+		assert.Equal(t, 5, remaining[0].Goods.Count())
+	}
+	assert.Empty(t, m.Bids(consumable.KeyApple))
+}
+
+func TestBidSweepsSellers(t *testing.T) {
+	m := NewMarket()
+
+	sellers := map[string]*trader{}
+	for i := 0; i < 5; i++ {
+		name := string(rune('a' + i))
+		sellers[name] = newTrader()
+		m.Push(consumable.KeyApple, Inventory{
+			Originator:         name,
+			Price:              float64(i + 1),
+			Goods:              apples(100),
+			Consumable:         consumable.NewApple(),
+			TransactionChannel: sellers[name].channel,
+		})
+	}
+
+	b := newTrader()
+	m.PlaceOrder(Order{
+		From:               "buyer",
+		Side:               Bid,
+		Quantity:           500,
+		Cash:               10000,
+		Consumable:         consumable.NewApple(),
+		FulfillmentChannel: b.channel,
+	})
+
+	assert.Len(t, b.Phase(PhaseCommit), 5)
+	for i := 0; i < 5; i++ {
+		name := string(rune('a' + i))
+		if paid := sellers[name].Phase(PhaseCommit); assert.Len(t, paid, 1) {
+			assert.Equal(t, float64(100*(i+1)), paid[0].CashIn)
+			assert.Equal(t, "buyer", paid[0].From)
 		}
-		wg.Done()
-	}()
-	wg.Wait()
+	}
+
+	fills := b.Fills()
+	if assert.Len(t, fills, 1) {
+		assert.Equal(t, 500, fills[0].Quantity)
+		assert.Equal(t, 1500.0, fills[0].Cost)
+		assert.Equal(t, 3.0, fills[0].AveragePrice())
+		assert.Len(t, fills[0].Sellers, 5)
+	}
+	assert.Empty(t, m.Bids(consumable.KeyApple))
+}
+
+func TestBidRestsAndTradesAtBidPrice(t *testing.T) {
+	m := NewMarket()
+	seller := newTrader()
+	b := newTrader()
+
+	m.PlaceOrder(Order{
+		From:               "buyer",
+		Side:               Bid,
+		Quantity:           10,
+		Price:              2,
+		Cash:               100,
+		Consumable:         consumable.NewApple(),
+		FulfillmentChannel: b.channel,
+	})
+
+	bids := m.Bids(consumable.KeyApple)
+	if assert.Len(t, bids, 1) {
+		assert.Equal(t, 10, bids[0].Quantity)
+	}
+
+	m.PlaceOrder(Order{
+		From:               "seller",
+		Side:               Ask,
+		Price:              1,
+		Goods:              apples(4),
+		Consumable:         consumable.NewApple(),
+		FulfillmentChannel: seller.channel,
+	})
+
+	if reserved := b.Phase(PhaseReserve); assert.Len(t, reserved, 1) {
+		assert.Equal(t, 8.0, reserved[0].CashOut)
+	}
+	if delivered := b.Phase(PhaseCommit); assert.Len(t, delivered, 1) {
+		assert.Equal(t, 4, delivered[0].ConsumablesIn.Count())
+	}
+	if paid := seller.Phase(PhaseCommit); assert.Len(t, paid, 1) {
+		assert.Equal(t, 8.0, paid[0].CashIn)
+	}
+
+	bids = m.Bids(consumable.KeyApple)
+	if assert.Len(t, bids, 1) {
+		assert.Equal(t, 6, bids[0].Quantity)
+		assert.Equal(t, 92.0, bids[0].Cash)
+	}
+}
+
+func TestBidPriority(t *testing.T) {
+	m := NewMarket()
+
+	for i, price := range []float64{1, 3, 2, 3} {
+		m.PlaceOrder(Order{
+			From:               string(rune('a' + i)),
+			Side:               Bid,
+			Quantity:           10,
+			Price:              price,
+			Cash:               100,
+			Consumable:         consumable.NewApple(),
+			FulfillmentChannel: newTrader().channel,
+		})
+	}
+
+	from := []string{}
+	for _, b := range m.Bids(consumable.KeyApple) {
+		from = append(from, b.From)
+	}
+	assert.Equal(t, []string{"b", "d", "c", "a"}, from)
+
+	// A new bid replaces the bidder's resting one
+	m.PlaceOrder(Order{
+		From:               "b",
+		Side:               Bid,
+		Quantity:           10,
+		Price:              1.5,
+		Cash:               100,
+		Consumable:         consumable.NewApple(),
+		FulfillmentChannel: newTrader().channel,
+	})
+
+	from = []string{}
+	for _, b := range m.Bids(consumable.KeyApple) {
+		from = append(from, b.From)
+	}
+	assert.Equal(t, []string{"d", "c", "b", "a"}, from)
+}
+
+func TestSpoil(t *testing.T) {
+	m := NewMarket()
+	seller := newTrader()
+
+	// Half picked on tick 1, half on tick 5
+	goods := consumable.Lots{}
+	for _, tick := range []int{1, 5} {
+		c := consumable.NewApple()
+		c.SetProduced(tick)
+		goods = goods.Add(consumable.NewLot(c, 5))
+	}
+	m.Push(consumable.KeyApple, Inventory{
+		Originator:         "seller",
+		Price:              1,
+		Goods:              goods,
+		Consumable:         consumable.NewApple(),
+		TransactionChannel: seller.channel,
+	})
+
+	m.Spoil(20)
+	assert.Equal(t, 10, m.Listed("seller"))
+
+	m.Spoil(21)
+	assert.Equal(t, 5, m.Listed("seller"))
+	if transactions := seller.Transactions(); assert.Len(t, transactions, 1) {
+		assert.Equal(t, 5, transactions[0].Spoiled)
+	}
+
+	m.Spoil(25)
+	assert.Equal(t, 0, m.Listed("seller"))
+	assert.Equal(t, 0, m.inventoryMap[consumable.KeyApple].Len())
+
+	r := m.MarketReports()[consumable.KeyApple]
+	assert.Equal(t, 10, r.Spoiled)
+	assert.Equal(t, 0, r.Stock)
+}
+
+func TestSpoilDoesNotWaitOnSilentSeller(t *testing.T) {
+	m := NewMarket()
+	m.SettlementTimeout = 10 * time.Millisecond
+
+	// Nobody ever reads this seller's channel
+	c := consumable.NewApple()
+	c.SetProduced(1)
+	m.Push(consumable.KeyApple, Inventory{
+		Originator:         "seller",
+		Price:              1,
+		Goods:              consumable.Lots{consumable.NewLot(c, 5)},
+		Consumable:         consumable.NewApple(),
+		TransactionChannel: make(chan Transaction),
+	})
+
+	m.Spoil(100)
+	assert.Equal(t, 0, m.Listed("seller"))
+}
+
+func benchmarkPush(b *testing.B, listings int) {
+	r := rand.New(rand.NewSource(1))
+	m := NewMarket()
+	list := func() {
+		m.Push(consumable.KeyApple, Inventory{
+			Originator: "seller",
+			Price:      float64(r.Intn(1000)) / 10,
+			Goods:      apples(10),
+			Consumable: consumable.NewApple(),
+		})
+	}
+	for i := 0; i < listings; i++ {
+		list()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		list()
+	}
+}
+
+func BenchmarkPush1000(b *testing.B)  { benchmarkPush(b, 1000) }
+func BenchmarkPush10000(b *testing.B) { benchmarkPush(b, 10000) }
+
+// benchmarkSortedPush lists the way Push did before asks
+// were kept in a heap, appending and re-sorting the book.
+func benchmarkSortedPush(b *testing.B, listings int) {
+	r := rand.New(rand.NewSource(1))
+	inventories := []Inventory{}
+	list := func() {
+		inventories = append(inventories, Inventory{
+			Originator: "seller",
+			Price:      float64(r.Intn(1000)) / 10,
+			Goods:      apples(10),
+			Consumable: consumable.NewApple(),
+		})
+		sort.SliceStable(inventories, func(i, j int) bool {
+			return inventories[i].Price < inventories[j].Price
+		})
+	}
+	for i := 0; i < listings; i++ {
+		list()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		list()
+	}
+}
+
+func BenchmarkSortedPush1000(b *testing.B)  { benchmarkSortedPush(b, 1000) }
+func BenchmarkSortedPush10000(b *testing.B) { benchmarkSortedPush(b, 10000) }
+
+func TestPopNConfirmRelists(t *testing.T) {
+	m := NewMarket()
+	for _, price := range []float64{2, 1, 3} {
+		m.Push(consumable.KeyApple, Inventory{
+			Price:      price,
+			Goods:      apples(10),
+			Consumable: consumable.NewApple(),
+		})
+	}
+
+	c, confirm := m.PopNConfirm(consumable.KeyApple, 4)
+	inv := <-c
+	assert.Equal(t, 1.0, inv.Price)
+	assert.Equal(t, 4, inv.Goods.Count())
+	confirm <- true
+	_, open := <-c
+	assert.False(t, open)
+
+	// What is left keeps its place at the front of the book
+	remaining := []Inventory{}
+	for inv := range m.Read(consumable.KeyApple) {
+		remaining = append(remaining, inv)
+	}
+	if assert.Len(t, remaining, 3) {
+		assert.Equal(t, 1.0, remaining[0].Price)
+		assert.Equal(t, 6, remaining[0].Goods.Count())
+		assert.Equal(t, 2.0, remaining[1].Price)
+		assert.Equal(t, 3.0, remaining[2].Price)
+	}
+}
+
+func TestSettlementRollsBack(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		buyer  chan Transaction
+		reason error
+	}{
+		{"refused", newRefuser().channel, ErrInsufficientFunds},
+		// Nobody ever reads this buyer's channel
+		{"unanswered", make(chan Transaction), ErrTimeout},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewMarket()
+			m.SettlementTimeout = 10 * time.Millisecond
+			seller := newTrader()
+			m.Push(consumable.KeyApple, Inventory{
+				Originator:         "seller",
+				Price:              1,
+				Goods:              apples(10),
+				Consumable:         consumable.NewApple(),
+				TransactionChannel: seller.channel,
+			})
+
+			m.PlaceOrder(Order{
+				From:               "buyer",
+				Side:               Bid,
+				Quantity:           4,
+				Price:              1,
+				Cash:               100,
+				Consumable:         consumable.NewApple(),
+				FulfillmentChannel: test.buyer,
+			})
+
+			// The seller hears why and keeps every apple
+			if failed := seller.Phase(PhaseRollback); assert.Len(t, failed, 1) {
+				assert.Equal(t, test.reason.Error(), failed[0].Failure)
+			}
+			assert.Empty(t, seller.Phase(PhaseCommit))
+			assert.Equal(t, 10, m.Listed("seller"))
+			assert.Empty(t, m.Bids(consumable.KeyApple))
+		})
+	}
+}
+
+func TestSettlementRollsBackUnpaidSeller(t *testing.T) {
+	m := NewMarket()
+	m.SettlementTimeout = 10 * time.Millisecond
+	m.Ledger = NewLedger()
+	buyer := newTrader()
+
+	// Nobody ever reads this seller's channel
+	m.Push(consumable.KeyApple, Inventory{
+		Originator:         "seller",
+		Price:              1,
+		Goods:              apples(10),
+		Consumable:         consumable.NewApple(),
+		TransactionChannel: make(chan Transaction),
+	})
+
+	m.PlaceOrder(Order{
+		From:               "buyer",
+		Side:               Bid,
+		Quantity:           4,
+		Price:              1,
+		Cash:               100,
+		Consumable:         consumable.NewApple(),
+		FulfillmentChannel: buyer.channel,
+	})
+
+	// The buyer gets its cash back and no apples
+	assert.Len(t, buyer.Phase(PhaseReserve), 1)
+	assert.Empty(t, buyer.Phase(PhaseCommit))
+	if failed := buyer.Phase(PhaseRollback); assert.Len(t, failed, 1) {
+		assert.Equal(t, ErrTimeout.Error(), failed[0].Failure)
+		assert.Equal(t, 4.0, failed[0].CashOut)
+	}
+	if fills := buyer.Fills(); assert.Len(t, fills, 1) {
+		assert.Equal(t, 0, fills[0].Quantity)
+	}
+
+	// Nothing is left with the market, and nothing sold
+	assert.Equal(t, 0.0, m.Ledger.Balance(AccountMarket))
+	assert.Equal(t, 0.0, m.Ledger.Balance("buyer"))
+	assert.Equal(t, 0, m.Listed("seller"))
+	assert.Equal(t, 0, m.MarketReport().ProductSold)
+}
+
+func TestSettlementWaitsOnTakenCommit(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		seller, buyer *trader
+	}{
+		{"slow seller", newSlowTrader(PhaseCommit, 30*time.Millisecond), newTrader()},
+		{"slow buyer", newTrader(), newSlowTrader(PhaseCommit, 30*time.Millisecond)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewMarket()
+			m.SettlementTimeout = 10 * time.Millisecond
+			m.Ledger = NewLedger()
+			m.Push(consumable.KeyApple, Inventory{
+				Originator:         "seller",
+				Price:              1,
+				Goods:              apples(10),
+				Consumable:         consumable.NewApple(),
+				TransactionChannel: test.seller.channel,
+			})
+
+			m.PlaceOrder(Order{
+				From:               "buyer",
+				Side:               Bid,
+				Quantity:           4,
+				Price:              1,
+				Cash:               100,
+				Consumable:         consumable.NewApple(),
+				FulfillmentChannel: test.buyer.channel,
+			})
+
+			// A commit answered late still stands
+			assert.Empty(t, test.seller.Phase(PhaseRollback))
+			assert.Empty(t, test.buyer.Phase(PhaseRollback))
+
+			// and what each side holds is what the ledger says
+			paid := 0.0
+			for _, c := range test.seller.Phase(PhaseCommit) {
+				paid += c.CashIn
+			}
+			bought := 0
+			for _, c := range test.buyer.Phase(PhaseCommit) {
+				bought += c.ConsumablesIn.Count()
+			}
+			assert.Equal(t, 4.0, paid)
+			assert.Equal(t, paid, m.Ledger.Balance("seller"))
+			assert.Equal(t, -paid, m.Ledger.Balance("buyer"))
+			assert.Equal(t, 0.0, m.Ledger.Balance(AccountMarket))
+			assert.Equal(t, 10, bought+m.Listed("seller"))
+		})
+	}
+}
+
+func TestFailedSellerIsPulled(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		seller chan Transaction
+	}{
+		{"refused", startTrader(PhasePrepare).channel},
+		// Nobody ever reads this seller's channel
+		{"unanswered", make(chan Transaction)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewMarket()
+			m.SettlementTimeout = 10 * time.Millisecond
+			bid := func(name string, price float64) *trader {
+				b := newTrader()
+				m.PlaceOrder(Order{
+					From:               name,
+					Side:               Bid,
+					Quantity:           4,
+					Price:              price,
+					Cash:               100,
+					Consumable:         consumable.NewApple(),
+					FulfillmentChannel: b.channel,
+				})
+				return b
+			}
+			ask := func(name string, price float64, c chan Transaction) {
+				m.Push(consumable.KeyApple, Inventory{
+					Originator:         name,
+					Price:              price,
+					Goods:              apples(12),
+					Consumable:         consumable.NewApple(),
+					TransactionChannel: c,
+				})
+			}
+
+			// Against resting bids the seller is pulled
+			// and every bid is kept
+			resting := []*trader{bid("a", 1), bid("b", 1), bid("c", 1)}
+			ask("dead", 1, test.seller)
+			assert.Len(t, m.Bids(consumable.KeyApple), 3)
+			assert.Equal(t, 0, m.Listed("dead"))
+
+			// A bid sweeping past it buys from the next ask
+			seller := newTrader()
+			ask("dead", 1.5, test.seller)
+			ask("seller", 2, seller.channel)
+			buyer := bid("buyer", 5)
+			if fills := buyer.Fills(); assert.Len(t, fills, 1) {
+				assert.Equal(t, 4, fills[0].Quantity)
+				assert.Equal(t, 2.0, fills[0].AveragePrice())
+			}
+			assert.Equal(t, 0, m.Listed("dead"))
+
+			// and the kept bids trade with the next seller
+			ask("seller", 1, seller.channel)
+			for _, b := range resting {
+				assert.Len(t, b.Phase(PhaseCommit), 1)
+			}
+			assert.Empty(t, m.Bids(consumable.KeyApple))
+		})
+	}
+}
+
+func TestReprice(t *testing.T) {
+	m := NewMarket()
+	a := newTrader()
+	b := newTrader()
+	buyer := newTrader()
+
+	m.PlaceOrder(Order{
+		From:               "buyer",
+		Side:               Bid,
+		Quantity:           5,
+		Price:              1.5,
+		Cash:               100,
+		Consumable:         consumable.NewApple(),
+		FulfillmentChannel: buyer.channel,
+	})
+	for _, ask := range []struct {
+		from  string
+		price float64
+		c     chan Transaction
+	}{{"a", 3, a.channel}, {"b", 2, b.channel}, {"a", 4, a.channel}} {
+		m.Push(consumable.KeyApple, Inventory{
+			Originator:         ask.from,
+			Price:              ask.price,
+			Goods:              apples(10),
+			Consumable:         consumable.NewApple(),
+			TransactionChannel: ask.c,
+		})
+	}
+
+	// Down to the resting bid, which takes
+	// five of the first listing repriced
+	m.Reprice("a", consumable.KeyApple, func(Inventory) float64 {
+		return 1.5
+	})
+
+	if paid := a.Phase(PhaseCommit); assert.Len(t, paid, 1) {
+		assert.Equal(t, 7.5, paid[0].CashIn)
+		assert.Equal(t, 5, paid[0].ConsumablesOut.Count())
+	}
+	assert.Empty(t, m.Bids(consumable.KeyApple))
+
+	listed := []Inventory{}
+	for inv := range m.Read(consumable.KeyApple) {
+		listed = append(listed, inv)
+	}
+	if assert.Len(t, listed, 3) {
+		assert.Equal(t, []float64{1.5, 1.5, 2}, []float64{listed[0].Price, listed[1].Price, listed[2].Price})
+		assert.Equal(t, 5, listed[0].Goods.Count())
+		assert.Equal(t, "b", listed[2].Originator)
+	}
+	assert.Equal(t, 15, m.ListedOf("a", consumable.KeyApple))
+	assert.Empty(t, b.Transactions())
 }
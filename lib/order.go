@@ -4,15 +4,85 @@ import (
 	"eco/lib/consumable"
 )
 
+// Side indicates which side of the book an Order is placed on.
+type Side int
+
+const (
+	// Bid is an order to buy.
+	Bid Side = iota
+	// Ask is an order to sell.
+	Ask
+)
+
 type Order struct {
-	Index              int
-	From               string
-	Cash               float64
-	Quantity           int
-	Consumable         consumable.Consumable
+	Index    int
+	From     string
+	Side     Side
+	Cash     float64
+	Quantity int
+
+	// Price is the limit price per unit. A Bid with a zero
+	// Price is a market order: it takes whatever is offered
+	// and never rests on the book.
+	Price float64
+
+	Consumable consumable.Consumable
+
+	// Goods are the units offered by an Ask.
+	Goods consumable.Lots
+
+	// Priority weighs the bid's share of the agent's
+	// cash against its other bids. Zero counts as one.
+	Priority int
+
+	// Ref is the placer's own reference for the order,
+	// echoed on its Fill and settlement transactions.
+	Ref int
+
 	FulfillmentChannel chan Transaction
 }
 
+// Fill summarises the trades made against an Order.
+type Fill struct {
+	OrderIndex int
+	OrderRef   int
+	Key        string
+	Quantity   int
+	Cost       float64
+
+	// Sellers holds the quantity bought from each seller.
+	Sellers map[string]int
+
+	// Resting is how much of the order is left on the
+	// book. Zero means the order is done with.
+	Resting int
+}
+
+// Add records quantity units bought from seller at price.
+func (f *Fill) Add(seller string, quantity int, price float64) {
+	if f.Sellers == nil {
+		f.Sellers = map[string]int{}
+	}
+	f.Sellers[seller] += quantity
+	f.Quantity += quantity
+	f.Cost += float64(quantity) * price
+}
+
+// AveragePrice returns the volume-weighted average price
+// paid across every trade in the fill.
+func (f *Fill) AveragePrice() float64 {
+	if f.Quantity == 0 {
+		return 0
+	}
+	return f.Cost / float64(f.Quantity)
+}
+
+// Crosses reports whether the order is willing to trade
+// with a resting ask at price.
+func (o *Order) Crosses(price float64) bool {
+	return o.Price == 0 || o.Price >= price
+}
+
 func (o *Order) CanAffordAt(inv Inventory) bool {
 	return o.Cash > float64(o.Quantity)*inv.Price
 }
@@ -25,8 +95,8 @@ func (o *Order) PurchasableQuantity(inv Inventory) int {
 	// Check to see if they can afford their desired quantity
 	// at this price.
 	quantity := o.Quantity
-	if quantity > len(inv.Goods) {
-		quantity = len(inv.Goods)
+	if stock := inv.Goods.Count(); quantity > stock {
+		quantity = stock
 	}
 
 	if !o.CanAffordQuantityAt(quantity, inv) {
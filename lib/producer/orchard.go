@@ -13,17 +13,21 @@ type orchard struct {
 	wage           float64
 	key            string
 	value          float64
-	products       []consumable.Consumable
+	depreciation   float64
+	maintenance    float64
+	condition      float64
 }
 
 func NewOrchard() Producer {
 	return &orchard{
-		rate:     10,
-		cost:     1,
-		wage:     5,
-		value:    .25,
-		key:      KeyOrchard,
-		products: []consumable.Consumable{},
+		rate:         10,
+		cost:         1,
+		wage:         5,
+		value:        200,
+		depreciation: .01,
+		maintenance:  2,
+		key:          KeyOrchard,
+		condition:    1,
 	}
 }
 
@@ -44,34 +48,45 @@ func (o *orchard) Key() string {
 }
 
 func (o *orchard) Value() float64 {
-	return o.value
+	return o.value * o.condition
+}
+
+// An orchard takes any number of workers.
+func (o *orchard) Workers() int {
+	return 0
+}
+
+func (o *orchard) Depreciation() float64 {
+	return o.depreciation
+}
+
+func (o *orchard) Maintenance() float64 {
+	return o.maintenance
+}
+
+func (o *orchard) Condition() float64 {
+	return o.condition
+}
+
+func (o *orchard) Depreciate() {
+	o.condition *= 1 - o.depreciation
 }
 
 func (o *orchard) Type() consumable.Consumable {
 	return consumable.NewApple()
 }
 
-func (o *orchard) Products() []consumable.Consumable {
-	p := o.products
-	o.products = []consumable.Consumable{}
-	return p
+func (o *orchard) Inputs() []Input {
+	return nil
 }
 
-func (o *orchard) Produce() (float64, float64, []consumable.Consumable) {
-	products := []consumable.Consumable{}
-	for i := 0; i < o.rate; i++ {
-		products = append(products, o.Type().Clone())
-	}
+func (o *orchard) Produce() (float64, float64, consumable.Lot) {
 	wage := float64(o.rate) * o.wage
 	cost := (float64(o.rate) * o.cost)
-	return cost, wage, products
+	return cost, wage, consumable.NewLot(o.Type(), o.rate)
 }
 
 func (o *orchard) Estimate() float64 {
-	// TODO: cache this?
-	for i := 0; i < o.rate; i++ {
-		o.products = append(o.products, o.Type().Clone())
-	}
 	wage := float64(o.rate) * o.wage
 	return (float64(o.rate) * o.cost) + wage
 }
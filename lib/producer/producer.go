@@ -5,6 +5,12 @@ import (
 	"eco/lib/consumable"
 )
 
+// Input is a good a production cycle uses up.
+type Input struct {
+	Consumable consumable.Consumable
+	Quantity   int
+}
+
 type Producer interface {
 	// Produce returns an int representing
 	// how much of a consumable is
@@ -14,6 +20,15 @@ type Producer interface {
 
 	Type() consumable.Consumable
 
+	// Workers returns how many workers can work the
+	// producer in a tick, each for one cycle.
+	// Zero takes any number.
+	Workers() int
+
+	// Inputs returns the goods one cycle uses up.
+	// Nil needs nothing but labor.
+	Inputs() []Input
+
 	// Wage returns the wage for an agent
 	// that produces N Product over one Interval.
 	Wage() float64
@@ -21,14 +36,31 @@ type Producer interface {
 	// Cost returns the cost to produce one unit.
 	Cost() float64
 
-	// Value returns the cost of the producer.
+	// Value returns what the producer is worth in its
+	// current condition.
 	Value() float64
 
+	// Depreciation returns the share of its condition
+	// the producer loses every tick.
+	Depreciation() float64
+
+	// Maintenance returns what it costs to keep
+	// the producer running for a tick.
+	Maintenance() float64
+
+	// Condition returns the share of its value
+	// the producer has left, one when new.
+	Condition() float64
+
+	// Depreciate wears the producer by one tick.
+	Depreciate()
+
 	// Key returns a string for use in maps
 	Key() string
 
-	// Produce returns the cost (including wage) and wage of one cycle of this Producer
-	Produce() (float64, float64, []consumable.Consumable)
+	// Produce returns the cost and wage of one cycle
+	// of this Producer, and the lot it produced.
+	Produce() (float64, float64, consumable.Lot)
 
 	// Estimate returns an "estimate"
 	// (i.e. I haven't implemented anything to estimate)
@@ -0,0 +1,196 @@
+package producer
+
+import (
+	"eco/lib/consumable"
+	"fmt"
+	"sort"
+)
+
+// Definition describes a producer without any Go of its
+// own, as it is written in a catalog.
+type Definition struct {
+	Key string `json:"key"`
+
+	// Output is the key of the good produced.
+	Output string `json:"output"`
+
+	// Rate is how many units one worker produces a cycle.
+	Rate int `json:"rate"`
+
+	// Cost is the cost to produce one unit.
+	Cost float64 `json:"cost"`
+
+	// Wage is paid per unit produced.
+	Wage float64 `json:"wage"`
+
+	// Workers is how many workers can work the producer
+	// in a tick. Zero takes any number.
+	Workers int `json:"workers,omitempty"`
+
+	// Value is what the producer itself is worth new.
+	Value float64 `json:"value"`
+
+	// Depreciation is the share of its condition
+	// the producer loses every tick.
+	Depreciation float64 `json:"depreciation,omitempty"`
+
+	// Maintenance is paid every tick
+	// to keep the producer running.
+	Maintenance float64 `json:"maintenance,omitempty"`
+
+	// Inputs is how many units of each good, by key,
+	// one cycle uses up.
+	Inputs map[string]int `json:"inputs,omitempty"`
+}
+
+// generic is a Producer built from a Definition.
+type generic struct {
+	Definition
+	output    consumable.Consumable
+	inputs    []Input
+	condition float64
+}
+
+func (g *generic) Rate() int {
+	return g.Definition.Rate
+}
+
+func (g *generic) Workers() int {
+	return g.Definition.Workers
+}
+
+func (g *generic) Wage() float64 {
+	return g.Definition.Wage
+}
+
+func (g *generic) Cost() float64 {
+	return g.Definition.Cost
+}
+
+func (g *generic) Key() string {
+	return g.Definition.Key
+}
+
+func (g *generic) Value() float64 {
+	return g.Definition.Value * g.condition
+}
+
+func (g *generic) Depreciation() float64 {
+	return g.Definition.Depreciation
+}
+
+func (g *generic) Maintenance() float64 {
+	return g.Definition.Maintenance
+}
+
+func (g *generic) Condition() float64 {
+	return g.condition
+}
+
+func (g *generic) Depreciate() {
+	g.condition *= 1 - g.Definition.Depreciation
+}
+
+func (g *generic) Type() consumable.Consumable {
+	return g.output.Clone()
+}
+
+func (g *generic) Inputs() []Input {
+	return g.inputs
+}
+
+func (g *generic) Produce() (float64, float64, consumable.Lot) {
+	wage := float64(g.Rate()) * g.Wage()
+	cost := float64(g.Rate()) * g.Cost()
+	return cost, wage, consumable.NewLot(g.Type(), g.Rate())
+}
+
+func (g *generic) Estimate() float64 {
+	return float64(g.Rate()) * (g.Cost() + g.Wage())
+}
+
+// Registry builds the producers it has definitions for,
+// out of the goods in Goods.
+type Registry struct {
+	Goods       *consumable.Registry
+	definitions map[string]Definition
+}
+
+func NewRegistry(goods *consumable.Registry) *Registry {
+	return &Registry{
+		Goods:       goods,
+		definitions: map[string]Definition{},
+	}
+}
+
+// Register adds d to the registry. Its output
+// must already be registered with Goods.
+func (r *Registry) Register(d Definition) error {
+	switch {
+	case d.Key == "":
+		return fmt.Errorf("producer: definition has no key")
+	case d.Rate < 1:
+		return fmt.Errorf("producer: %s: rate must be at least 1", d.Key)
+	case d.Workers < 0:
+		return fmt.Errorf("producer: %s: workers cannot be negative", d.Key)
+	case d.Cost < 0 || d.Wage < 0 || d.Value < 0 || d.Maintenance < 0:
+		return fmt.Errorf("producer: %s: cost, wage, value and maintenance cannot be negative", d.Key)
+	case d.Depreciation < 0 || d.Depreciation > 1:
+		return fmt.Errorf("producer: %s: depreciation must be between 0 and 1", d.Key)
+	}
+	if _, ok := r.Goods.Definition(d.Output); !ok {
+		return fmt.Errorf("producer: %s: unknown output %q", d.Key, d.Output)
+	}
+	for key, q := range d.Inputs {
+		if _, ok := r.Goods.Definition(key); !ok {
+			return fmt.Errorf("producer: %s: unknown input %q", d.Key, key)
+		}
+		if q < 1 {
+			return fmt.Errorf("producer: %s: needs at least 1 %s", d.Key, key)
+		}
+	}
+	if _, ok := r.definitions[d.Key]; ok {
+		return fmt.Errorf("producer: %s is already registered", d.Key)
+	}
+	r.definitions[d.Key] = d
+	return nil
+}
+
+// New returns a new producer registered under key.
+func (r *Registry) New(key string) (Producer, error) {
+	d, ok := r.definitions[key]
+	if !ok {
+		return nil, fmt.Errorf("producer: unknown producer %q", key)
+	}
+	output, err := r.Goods.New(d.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	// Inputs in key order, so they are bought in a fixed sequence
+	keys := []string{}
+	for key := range d.Inputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	inputs := []Input{}
+	for _, key := range keys {
+		c, err := r.Goods.New(key)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, Input{Consumable: c, Quantity: d.Inputs[key]})
+	}
+	return &generic{Definition: d, output: output, inputs: inputs, condition: 1}, nil
+}
+
+// Keys returns the key of every registered producer, sorted.
+func (r *Registry) Keys() []string {
+	keys := []string{}
+	for key := range r.definitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
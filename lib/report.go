@@ -4,22 +4,68 @@ type Report struct {
 	Wealth        float64
 	Consumables   int
 	WagesMade     float64
+	Consumed      int
 	UnmetDemand   int
+	Spoiled       int
+	Purchased     int
+	PurchaseCost  float64
 	Revenue       float64
 	Costs         float64
 	SentToMarket  int
 	WagesPaid     float64
 	Production    int
+	InputsUsed    int
 	ProductCylces int
 	Employees     int
 	Hired         int
 	Fired         int
+
+	FailedSettlements int
+
+	TaxesPaid float64
+	Transfers float64
+
+	Deposit        float64
+	Debt           float64
+	InterestEarned float64
+	InterestPaid   float64
+	Defaults       int
+
+	Founded int
+
+	Producers    int
+	Invested     float64
+	Divested     float64
+	Maintenance  float64
+	Depreciation float64
+
+	// Skill is the agent's best skill at the producers
+	// it has one listed for, one when it has none.
+	Skill float64
 }
 
 type MarketReport struct {
+	Key              string
 	TotalCashFlow    float64
 	TotalProductFlow int
 	ProductReceived  int
 	ProductSold      int
 	AveragePrice     float64
+	Stock            int
+	Spoiled          int
+}
+
+// Add accumulates other into r, recomputing the average price.
+func (r *MarketReport) Add(other MarketReport) {
+	r.TotalCashFlow += other.TotalCashFlow
+	r.TotalProductFlow += other.TotalProductFlow
+	r.ProductReceived += other.ProductReceived
+	r.ProductSold += other.ProductSold
+	r.Stock += other.Stock
+	r.Spoiled += other.Spoiled
+
+	r.AveragePrice = 0
+	if r.ProductSold > 0 {
+		r.AveragePrice = r.TotalCashFlow / float64(r.ProductSold)
+	}
 }
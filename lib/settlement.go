@@ -0,0 +1,264 @@
+package lib
+
+import (
+	"eco/lib/consumable"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Phase is the step of a settlement a Transaction carries.
+type Phase int
+
+const (
+	// PhaseNone is a transaction outside of any settlement.
+	PhaseNone Phase = iota
+
+	// PhaseReserve asks the buyer to move CashOut into escrow.
+	PhaseReserve
+
+	// PhasePrepare asks the seller whether it is there
+	// to be paid for ConsumablesOut. Nothing changes hands.
+	PhasePrepare
+
+	// PhaseCommit spends the escrow: the buyer receives
+	// ConsumablesIn and the seller receives CashIn.
+	PhaseCommit
+
+	// PhaseRollback cancels the escrow, returning any cash
+	// it holds to the buyer. A seller that was already paid
+	// for ConsumablesOut hands back CashOut. Failure says why.
+	PhaseRollback
+)
+
+// Reasons a settlement fails.
+var (
+	ErrInsufficientFunds = errors.New("buyer could not cover the escrow")
+	ErrRefused           = errors.New("seller refused the trade")
+	ErrTimeout           = errors.New("counterparty did not answer in time")
+	ErrNoAnswer          = errors.New("counterparty took the transaction but did not answer in time")
+)
+
+// SettlementError is returned when the escrow
+// of a trade could not be committed.
+type SettlementError struct {
+	Escrow int
+
+	// Side is who failed: Bid for the buyer,
+	// Ask for the seller.
+	Side   Side
+	Reason error
+}
+
+func (e *SettlementError) Error() string {
+	return fmt.Sprintf("escrow %d: %v", e.Escrow, e.Reason)
+}
+
+func (e *SettlementError) Unwrap() error {
+	return e.Reason
+}
+
+// Escrow is a trade being settled: goods taken off the book
+// for a buyer, and the cash the buyer has to put up for them.
+type Escrow struct {
+	ID     int
+	Order  Order
+	Seller Inventory
+	Goods  consumable.Lots
+	Price  float64
+}
+
+// Total returns the cash the escrow holds once reserved.
+func (e Escrow) Total() float64 {
+	return float64(e.Goods.Count()) * e.Price
+}
+
+// deliverWithin sends t on c and waits for the receiver to
+// process it, giving up after timeout. Zero waits forever.
+// ErrTimeout means t was never taken, ErrNoAnswer that it
+// was taken and may yet be applied.
+func deliverWithin(c chan Transaction, t Transaction, timeout time.Duration) (bool, error) {
+	// Buffered, so a late answer never blocks the receiver
+	t.AcceptChannel = make(chan bool, 1)
+	t.ResponseRequired = true
+
+	if timeout <= 0 {
+		c <- t
+		return <-t.AcceptChannel, nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case c <- t:
+	case <-timer.C:
+		return false, ErrTimeout
+	}
+
+	select {
+	case accepted := <-t.AcceptChannel:
+		return accepted, nil
+	case <-timer.C:
+		return false, ErrNoAnswer
+	}
+}
+
+// commitWithin is deliverWithin for a transaction that moves
+// cash or goods. It gives up only when t is not taken within
+// timeout: once taken, t is final and its answer is waited
+// for, so that nothing the receiver applied is left undone.
+func commitWithin(c chan Transaction, t Transaction, timeout time.Duration) (bool, error) {
+	t.AcceptChannel = make(chan bool, 1)
+	t.ResponseRequired = true
+
+	if timeout <= 0 {
+		c <- t
+		return <-t.AcceptChannel, nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case c <- t:
+		return <-t.AcceptChannel, nil
+	case <-timer.C:
+		return false, ErrTimeout
+	}
+}
+
+// settle settles e in two phases. The buyer's cash is first
+// reserved in escrow, the goods were already taken off the
+// book, and the seller is asked to be ready to be paid.
+// Only once both have answered is the escrow committed:
+// cash to the seller, then goods to the buyer. Should either
+// side fail, the trade is rolled back, both sides are told
+// why and a SettlementError saying which side failed is
+// returned. The caller must not hold m.rwLock.
+func (m *Market) settle(e Escrow) error {
+	key := e.Seller.Consumable.Key()
+	quantity := e.Goods.Count()
+	total := e.Total()
+	memo := fmt.Sprintf("Order %d: %d %s", e.Order.Index, quantity, key)
+
+	accepted, err := commitWithin(e.Order.FulfillmentChannel, Transaction{
+		Phase:         PhaseReserve,
+		Escrow:        e.ID,
+		CashOut:       total,
+		ConsumableKey: key,
+		From:          e.Seller.Originator,
+		OrderIndex:    e.Order.Index,
+		OrderRef:      e.Order.Ref,
+	}, m.SettlementTimeout)
+	if err == nil && !accepted {
+		err = ErrInsufficientFunds
+	}
+	if err != nil {
+		m.rollback(e, err, false, false)
+		return &SettlementError{Escrow: e.ID, Side: Bid, Reason: err}
+	}
+	m.Ledger.Transfer(e.Order.From, AccountMarket, total, memo)
+
+	// Nothing leaves escrow until the seller has answered
+	accepted, err = deliverWithin(e.Seller.TransactionChannel, Transaction{
+		Phase:          PhasePrepare,
+		Escrow:         e.ID,
+		ConsumableKey:  key,
+		ConsumablesOut: e.Goods,
+		From:           e.Order.From,
+		OrderIndex:     e.Order.Index,
+	}, m.SettlementTimeout)
+	if err == nil && !accepted {
+		err = ErrRefused
+	}
+	if err != nil {
+		return m.refund(e, memo, Ask, err, false)
+	}
+
+	// Sales tax comes out of what the seller is paid
+	tax := m.Government.Due(TaxSales, total)
+	if _, err := commitWithin(e.Seller.TransactionChannel, Transaction{
+		Phase:          PhaseCommit,
+		Escrow:         e.ID,
+		CashIn:         total - tax,
+		Tax:            tax,
+		ConsumableKey:  key,
+		ConsumablesOut: e.Goods,
+		From:           e.Order.From,
+		OrderIndex:     e.Order.Index,
+	}, m.SettlementTimeout); err != nil {
+		return m.refund(e, memo, Ask, err, false)
+	}
+
+	if _, err := commitWithin(e.Order.FulfillmentChannel, Transaction{
+		Phase:         PhaseCommit,
+		Escrow:        e.ID,
+		ConsumableKey: key,
+		ConsumablesIn: e.Goods,
+		From:          e.Seller.Originator,
+		OrderIndex:    e.Order.Index,
+	}, m.SettlementTimeout); err != nil {
+		return m.refund(e, memo, Bid, err, true)
+	}
+
+	m.Ledger.Transfer(AccountMarket, e.Seller.Originator, total-tax, memo)
+	m.Government.Collect(TaxSales, AccountMarket, tax, "Sales tax on "+memo)
+
+	fmtDebug("\tOrder %d: %s bought %d %s from %s at %.2f\n", e.Order.Index, e.Order.From, quantity, key, e.Seller.Originator, e.Price)
+	return nil
+}
+
+// refund rolls back e once the buyer's cash is held by the
+// market, handing it back on the ledger, and returns the
+// SettlementError of side for reason. paid says whether
+// the seller was already paid.
+func (m *Market) refund(e Escrow, memo string, side Side, reason error, paid bool) error {
+	m.Ledger.Transfer(AccountMarket, e.Order.From, e.Total(), "Refund of "+memo)
+	m.rollback(e, reason, true, paid)
+	return &SettlementError{Escrow: e.ID, Side: side, Reason: reason}
+}
+
+// rollback tells both sides of e that it failed. A buyer
+// whose cash is reserved gets it back, and a seller that was
+// already paid hands its payment back. Both are waited on
+// however long they take, as their cash has to come back.
+func (m *Market) rollback(e Escrow, reason error, reserved bool, paid bool) {
+	key := e.Seller.Consumable.Key()
+	fmtDebug("\tOrder %d: escrow %d rolled back: %v\n", e.Order.Index, e.ID, reason)
+
+	buyerTimeout := m.SettlementTimeout
+	if reserved {
+		buyerTimeout = 0
+	}
+
+	// The buyer is told what it was asked for, and
+	// that its order is dropped
+	deliverWithin(e.Order.FulfillmentChannel, Transaction{
+		Phase:         PhaseRollback,
+		Escrow:        e.ID,
+		CashOut:       e.Total(),
+		Failure:       reason.Error(),
+		ConsumableKey: key,
+		From:          e.Seller.Originator,
+		OrderIndex:    e.Order.Index,
+		OrderRef:      e.Order.Ref,
+	}, buyerTimeout)
+
+	seller := Transaction{
+		Phase:         PhaseRollback,
+		Escrow:        e.ID,
+		Failure:       reason.Error(),
+		ConsumableKey: key,
+		From:          e.Order.From,
+		OrderIndex:    e.Order.Index,
+	}
+	if !paid {
+		deliverWithin(e.Seller.TransactionChannel, seller, m.SettlementTimeout)
+		return
+	}
+	seller.Tax = m.Government.Due(TaxSales, e.Total())
+	seller.CashOut = e.Total() - seller.Tax
+	seller.ConsumablesOut = e.Goods
+	deliver(e.Seller.TransactionChannel, seller)
+}
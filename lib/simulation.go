@@ -0,0 +1,618 @@
+package lib
+
+import (
+	"context"
+	"eco/lib/producer"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Config holds the settings of a Simulation.
+type Config struct {
+	// Seed seeds the Simulation's random source.
+	// Zero seeds it from the current time.
+	Seed int64
+
+	// Deterministic runs agents one at a time, in the order
+	// they were added, and has the market match each order
+	// as it is submitted, so that a seed reproduces a run.
+	Deterministic bool
+
+	// Ticks is the number of ticks Run performs.
+	// Zero runs until the context is done or Stop is called.
+	Ticks int
+
+	// Interval is how long Run waits between ticks.
+	Interval time.Duration
+
+	// SettlementTimeout is how long the market waits on
+	// either side of a trade before rolling it back.
+	// Zero waits forever.
+	SettlementTimeout time.Duration
+
+	// Policy is what the Government taxes and pays.
+	// The zero Policy leaves all cash with the agents.
+	Policy Policy
+
+	// Banking sets the terms of the bank. The zero
+	// BankPolicy runs without a bank.
+	Banking BankPolicy
+
+	// BankruptAfter is how many ticks in a row an agent may
+	// be unable to pay for a production cycle before it is
+	// liquidated and leaves. Zero never liquidates.
+	BankruptAfter int
+
+	// EntryWealth is the cash a consumer needs to found a
+	// firm. Zero founds none.
+	EntryWealth float64
+
+	// Catalog holds the producers firms are founded with
+	// and the capital market builds. Nil uses DefaultCatalog.
+	Catalog *Catalog
+
+	// OnTick, when set, is called by Run with the
+	// report of every tick.
+	OnTick func(TickReport)
+
+	// Exporters are handed the report of every tick Run
+	// performs, and are closed by Stop.
+	Exporters []Exporter
+}
+
+// TickReport is everything a Simulation reports about one tick.
+type TickReport struct {
+	Tick int
+
+	// Agents holds the ReportRecord of every agent,
+	// in the order the agents were added.
+	Agents [][]string
+
+	// AgentRecords holds the state of every agent,
+	// in the order the agents were added.
+	AgentRecords []AgentRecord
+
+	Market       MarketReport
+	MarketRecord []string
+
+	// Markets holds the activity of each market key,
+	// sorted by key.
+	Markets []MarketRecord
+
+	// Government holds what was taxed and paid out.
+	Government GovernmentRecord
+
+	// Bank holds what was deposited, lent and repaid.
+	Bank BankRecord
+
+	// Bankrupt and Founded name the agents that left
+	// and the firms that were founded.
+	Bankrupt []string
+	Founded  []string
+
+	// Capital holds the producers built, resold and scrapped.
+	Capital CapitalReport
+}
+
+// Simulation owns a Market, a LaborMarket and the agents
+// acting on them, and advances them one tick at a time.
+type Simulation struct {
+	Config Config
+
+	market      *Market
+	laborMarket *LaborMarket
+	ledger      *Ledger
+	government  *Government
+	bank        *Bank
+	capital     *CapitalMarket
+	agents      []*Agent
+	exited      []*Agent
+	rand        *rand.Rand
+
+	tick     int
+	started  bool
+	stop     chan bool
+	stopOnce sync.Once
+	rwLock   sync.Mutex
+}
+
+// NewSimulation returns a Simulation with an empty
+// Market and LaborMarket.
+func NewSimulation(config Config) *Simulation {
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+
+	ledger := NewLedger()
+	government := NewGovernment(config.Policy)
+	government.Ledger = ledger
+	m := NewMarket()
+	m.Synchronous = config.Deterministic
+	m.SettlementTimeout = config.SettlementTimeout
+	m.Ledger = ledger
+	m.Government = government
+	l := NewLaborMarket()
+
+	var bank *Bank
+	if config.Banking != (BankPolicy{}) {
+		bank = NewBank(config.Banking)
+		bank.Ledger = ledger
+		ledger.Transfer(AccountEndowment, AccountBank, bank.Reserves(), "Bank capital")
+	}
+
+	catalog := config.Catalog
+	if catalog == nil {
+		catalog = DefaultCatalog()
+	}
+	capital := NewCapitalMarket(catalog)
+	capital.Ledger = ledger
+
+	return &Simulation{
+		Config:      config,
+		market:      &m,
+		laborMarket: &l,
+		ledger:      ledger,
+		government:  government,
+		bank:        bank,
+		capital:     capital,
+		agents:      []*Agent{},
+		rand:        rand.New(rand.NewSource(config.Seed)),
+		stop:        make(chan bool),
+	}
+}
+
+// Market returns the Simulation's Market.
+func (s *Simulation) Market() *Market {
+	return s.market
+}
+
+// LaborMarket returns the Simulation's LaborMarket.
+func (s *Simulation) LaborMarket() *LaborMarket {
+	return s.laborMarket
+}
+
+// Ledger returns the Ledger recording every
+// movement of cash in the Simulation.
+func (s *Simulation) Ledger() *Ledger {
+	return s.ledger
+}
+
+// Government returns the Government
+// taxing the Simulation's agents.
+func (s *Simulation) Government() *Government {
+	return s.government
+}
+
+// Bank returns the Simulation's Bank,
+// nil when it runs without one.
+func (s *Simulation) Bank() *Bank {
+	return s.bank
+}
+
+// CapitalMarket returns the market the
+// Simulation's producers are bought and sold on.
+func (s *Simulation) CapitalMarket() *CapitalMarket {
+	return s.capital
+}
+
+// Rand returns the Simulation's random source. Every
+// random draw should come from it for a seed to
+// reproduce a run.
+func (s *Simulation) Rand() *rand.Rand {
+	return s.rand
+}
+
+// Agents returns the agents in the order they were added.
+func (s *Simulation) Agents() []*Agent {
+	s.rwLock.Lock()
+	defer s.rwLock.Unlock()
+
+	return append([]*Agent{}, s.agents...)
+}
+
+// Tick returns the number of ticks run so far.
+func (s *Simulation) Tick() int {
+	s.rwLock.Lock()
+	defer s.rwLock.Unlock()
+
+	return s.tick
+}
+
+// AddAgent adds agents to the Simulation. They should
+// have been created against its Market and LaborMarket.
+// Names are made unique, since the market and the ledger
+// know agents by name, and the cash each agent starts with
+// is recorded as an endowment.
+func (s *Simulation) AddAgent(agents ...*Agent) {
+	s.rwLock.Lock()
+	defer s.rwLock.Unlock()
+
+	for _, a := range agents {
+		a.Name = s.uniqueName(a.Name)
+		a.Ledger = s.ledger
+		a.Government = s.government
+		a.Bank = s.bank
+		a.Capital = s.capital
+		s.ledger.Transfer(AccountEndowment, a.Name, a.Cash, "Starting cash")
+
+		s.agents = append(s.agents, a)
+		if s.started {
+			go a.Start()
+		}
+	}
+}
+
+// uniqueName returns name, numbered if an agent, or one
+// that has left, already has it. The caller must hold
+// s.rwLock.
+func (s *Simulation) uniqueName(name string) string {
+	taken := map[string]bool{}
+	for _, a := range s.agents {
+		taken[a.Name] = true
+	}
+	for _, a := range s.exited {
+		taken[a.Name] = true
+	}
+
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s %d", name, i)
+	}
+	return unique
+}
+
+// CheckLedger checks the cash every agent holds against the
+// Ledger. It is only exact between ticks of a Deterministic
+// Simulation, when no order is still being settled.
+func (s *Simulation) CheckLedger() error {
+	held := map[string]float64{
+		AccountMarket:   0,
+		AccountTreasury: s.government.Treasury(),
+	}
+	if s.bank != nil {
+		held[AccountBank] = s.bank.Reserves()
+	}
+
+	// Agents that left still hold what they had
+	s.rwLock.Lock()
+	agents := append(append([]*Agent{}, s.agents...), s.exited...)
+	s.rwLock.Unlock()
+
+	for _, a := range agents {
+		a.rwLock.Lock()
+		held[a.Name] = a.Cash
+		a.rwLock.Unlock()
+	}
+	return s.ledger.Check(held)
+}
+
+// start starts the market and every agent.
+// The caller must hold s.rwLock.
+func (s *Simulation) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+
+	go s.market.Start()
+	for _, a := range s.agents {
+		go a.Start()
+	}
+}
+
+// Step runs a single tick and returns its report.
+func (s *Simulation) Step() TickReport {
+	s.rwLock.Lock()
+	defer s.rwLock.Unlock()
+
+	s.start()
+	s.tick++
+	s.ledger.SetTick(s.tick)
+
+	// Throw out what has gone off before anyone acts
+	s.market.Spoil(s.tick)
+	s.capital.Depreciate()
+	for _, a := range s.agents {
+		a.SetTick(s.tick)
+	}
+
+	// Loans fall due before anyone spends
+	s.bank.Service(s.agents)
+
+	records := make([][]string, len(s.agents))
+	if s.Config.Deterministic {
+		for i := range s.agents {
+			records[i] = s.agents[i].Actions()
+		}
+	} else {
+		wg := sync.WaitGroup{}
+		wg.Add(len(s.agents))
+		for i := range s.agents {
+			go func(i int) {
+				records[i] = s.agents[i].Actions()
+				wg.Done()
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	s.laborMarket.Match()
+
+	// Taxes on the tick are due before
+	// transfers are paid out of them
+	s.government.LevyCorporate(s.agents)
+	s.government.PayTransfers(s.agents)
+
+	bankrupt := s.exit()
+	s.windUp()
+	founded := s.enter()
+
+	agentRecords := make([]AgentRecord, len(s.agents))
+	for i := range s.agents {
+		agentRecords[i] = s.agents[i].Record(s.tick)
+	}
+
+	returnedRecord := make(chan []string)
+	s.market.ReportChannel <- returnedRecord
+	marketRecord := <-returnedRecord
+
+	total := MarketReport{}
+	markets := []MarketRecord{}
+	for _, r := range s.market.MarketReports() {
+		total.Add(r)
+		markets = append(markets, MarketRecord{Tick: s.tick, MarketReport: r})
+	}
+	sort.Slice(markets, func(i, j int) bool {
+		return markets[i].Key < markets[j].Key
+	})
+
+	return TickReport{
+		Tick:         s.tick,
+		Agents:       records,
+		AgentRecords: agentRecords,
+		MarketRecord: marketRecord,
+		Market:       total,
+		Markets:      markets,
+		Government:   GovernmentRecord{Tick: s.tick, GovernmentReport: s.government.Report()},
+		Bank:         BankRecord{Tick: s.tick, BankReport: s.bank.Report()},
+		Bankrupt:     bankrupt,
+		Founded:      founded,
+		Capital:      s.capital.Report(),
+	}
+}
+
+// exit liquidates every agent that has been insolvent for
+// longer than Config.BankruptAfter, and takes it out of the
+// tick. Its producers are put up for sale on the capital
+// market, and what they and its goods fetch is paid out by
+// windUp.
+// The caller must hold s.rwLock.
+func (s *Simulation) exit() []string {
+	if s.Config.BankruptAfter < 1 {
+		return nil
+	}
+
+	bankrupt := []string{}
+	active := []*Agent{}
+	for _, a := range s.agents {
+		if a.Insolvent() <= s.Config.BankruptAfter {
+			active = append(active, a)
+			continue
+		}
+
+		for _, p := range a.Liquidate("bankrupt") {
+			s.capital.List(a, p)
+		}
+		s.exited = append(s.exited, a)
+		bankrupt = append(bankrupt, a.Name)
+	}
+	s.agents = active
+	return bankrupt
+}
+
+// windUp pays out the cash of every agent that has exited:
+// to the bank first, towards what it wrote off on the agent,
+// and the rest to its Owner or, when it has none still in
+// the tick, in equal shares to every agent that is.
+// The caller must hold s.rwLock.
+func (s *Simulation) windUp() {
+	for _, a := range s.exited {
+		cash := a.Available()
+		cash -= s.bank.Recover(a, cash)
+		if cash < 1e-9 || len(s.agents) < 1 {
+			continue
+		}
+
+		heirs := s.agents
+		for _, other := range s.agents {
+			if other == a.Owner {
+				heirs = []*Agent{other}
+				break
+			}
+		}
+
+		memo := fmt.Sprintf("Estate of %s", a.Name)
+		if !deliver(a.TransactionChannel, Transaction{
+			CashOut: cash,
+			Memo:    memo,
+		}) {
+			continue
+		}
+		share := cash / float64(len(heirs))
+		for i, heir := range heirs {
+			if i == len(heirs)-1 {
+				share = cash - share*float64(i)
+			}
+			s.ledger.Transfer(a.Name, heir.Name, share, memo)
+			deliver(heir.TransactionChannel, Transaction{
+				CashIn:  share,
+				Memo:    memo,
+				Capital: true,
+				From:    a.Name,
+			})
+		}
+		fmtDebug("%s was wound up, %.2f paid out to %d.\n", a.Name, cash, len(heirs))
+	}
+}
+
+// enter has the richest consumer with at least
+// Config.EntryWealth in cash found a firm with half of it.
+// The firm takes up the producer and markups of the richest
+// firm, or the first producer in the catalog when there is
+// none, and buys its producer on the capital market.
+// The caller must hold s.rwLock.
+func (s *Simulation) enter() []string {
+	if s.Config.EntryWealth <= 0 {
+		return nil
+	}
+
+	var founder, model *Agent
+	wealth := s.Config.EntryWealth
+	for _, a := range s.agents {
+		if len(a.Producers) > 0 {
+			if model == nil || a.Balance() > model.Balance() {
+				model = a
+			}
+			continue
+		}
+		if cash := a.Available(); cash >= wealth && (founder == nil || cash > wealth) {
+			founder, wealth = a, cash
+		}
+	}
+	if founder == nil {
+		return nil
+	}
+
+	keys := s.capital.Catalog.Producers.Keys()
+	if len(keys) < 1 {
+		return nil
+	}
+	key := keys[0]
+	if model != nil {
+		if _, ok := s.capital.Quote(model.Producers[0].Key()); ok {
+			key = model.Producers[0].Key()
+		}
+	}
+
+	stake := wealth / 2
+	if price, ok := s.capital.Quote(key); !ok || stake < price {
+		return nil
+	}
+
+	firm := NewAgent(s.market, s.laborMarket)
+	firm.Name = s.uniqueName(fmt.Sprintf("%s's %s", founder.Name, key))
+	firm.Inventory = map[string]Inventory{}
+	firm.Ledger = s.ledger
+	firm.Government = s.government
+	firm.Bank = s.bank
+	firm.Capital = s.capital
+	firm.Owner = founder
+
+	memo := fmt.Sprintf("Founding %s", firm.Name)
+	if !deliver(founder.TransactionChannel, Transaction{
+		CashOut: stake,
+		Memo:    memo,
+		From:    firm.Name,
+	}) {
+		return nil
+	}
+	firm.Cash = stake
+	s.ledger.Transfer(founder.Name, firm.Name, stake, memo)
+
+	// The producer comes out of the stake,
+	// the rest is the firm's to start with
+	firm.Start()
+	p := s.capital.Buy(&firm, key)
+	if p != nil {
+		firm.Producers = []producer.Producer{p}
+	}
+	if model != nil {
+		firm.Greed = model.Greed
+		firm.PriceRaise = model.PriceRaise
+		firm.PriceCut = model.PriceCut
+		firm.Reinvest = model.Reinvest
+		firm.Strategy = model.Strategy
+		if p != nil {
+			output := p.Type().Key()
+			firm.markup[output] = model.Markup(output)
+		}
+	}
+
+	founder.rwLock.Lock()
+	founder.Report.Founded++
+	founder.rwLock.Unlock()
+
+	firm.SetTick(s.tick)
+	s.agents = append(s.agents, &firm)
+	fmtDebug("%s founded %s.\n", founder.Name, firm.Name)
+	return []string{firm.Name}
+}
+
+// Run steps the Simulation until ctx is done, Config.Ticks
+// ticks have run or Stop is called.
+func (s *Simulation) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.stop:
+			return nil
+		default:
+		}
+
+		report := s.Step()
+		if s.Config.OnTick != nil {
+			s.Config.OnTick(report)
+		}
+		for _, e := range s.Config.Exporters {
+			if err := e.Export(report); err != nil {
+				return err
+			}
+		}
+
+		if s.Config.Ticks > 0 && report.Tick >= s.Config.Ticks {
+			return nil
+		}
+
+		if s.Config.Interval > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-s.stop:
+				return nil
+			case <-time.After(s.Config.Interval):
+			}
+		}
+	}
+}
+
+// Stop ends Run after the current tick and
+// shuts down the market and agents.
+func (s *Simulation) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+
+		s.rwLock.Lock()
+		defer s.rwLock.Unlock()
+
+		for _, e := range s.Config.Exporters {
+			if err := e.Close(); err != nil {
+				log(err)
+			}
+		}
+
+		if !s.started {
+			return
+		}
+		s.market.Quit()
+		for _, a := range s.agents {
+			a.Quit()
+		}
+		for _, a := range s.exited {
+			a.Quit()
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package lib
+
+import (
+	"context"
+	"eco/lib/consumable"
+	"eco/lib/producer"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTestSimulation(config Config) *Simulation {
+	s := NewSimulation(config)
+	r := s.Rand()
+
+	for i := 0; i < 6; i++ {
+		a := NewAgent(s.Market(), s.LaborMarket())
+		a.Name = fmt.Sprintf("consumer%d", i)
+		a.SeeksWage = true
+		a.Cash = float64(r.Intn(1500) + 500)
+		a.ReservationWage = float64(r.Intn(40) + 20)
+		a.Demands = []consumable.Demand{{
+			Consumable: consumable.NewApple(),
+			Quantity:   r.Intn(500) + 50,
+			Price:      float64(r.Intn(50) + 10),
+		}}
+		s.AddAgent(&a)
+	}
+
+	for i := 0; i < 2; i++ {
+		a := NewAgent(s.Market(), s.LaborMarket())
+		a.Name = fmt.Sprintf("supplier%d", i)
+		a.Cash = float64(r.Intn(1500) + 500)
+		a.Greed = r.Intn(100) + 20
+		a.Producers = []producer.Producer{producer.NewOrchard()}
+		a.Inventory = map[string]Inventory{}
+		s.AddAgent(&a)
+	}
+	return s
+}
+
+func TestSimulationDeterministic(t *testing.T) {
+	run := func() []TickReport {
+		reports := []TickReport{}
+		s := newTestSimulation(Config{
+			Seed:          42,
+			Deterministic: true,
+			Ticks:         10,
+		})
+		s.Config.OnTick = func(r TickReport) {
+			reports = append(reports, r)
+			assert.NoError(t, s.CheckLedger())
+		}
+		assert.NoError(t, s.Run(context.Background()))
+		s.Stop()
+		return reports
+	}
+
+	first := run()
+	assert.Len(t, first, 10)
+	assert.Equal(t, first, run())
+}
+
+func TestSimulationStep(t *testing.T) {
+	s := newTestSimulation(Config{Seed: 1})
+	defer s.Stop()
+
+	for i := 1; i <= 3; i++ {
+		r := s.Step()
+		assert.Equal(t, i, r.Tick)
+		assert.Len(t, r.Agents, len(s.Agents()))
+	}
+	assert.Equal(t, 3, s.Tick())
+}
+
+func TestBankruptcyAndEntry(t *testing.T) {
+	s := NewSimulation(Config{Seed: 1, Deterministic: true, BankruptAfter: 1, EntryWealth: 1000})
+	defer s.Stop()
+
+	broke := NewAgent(s.Market(), s.LaborMarket())
+	broke.Name = "broke"
+	broke.Producers = []producer.Producer{producer.NewOrchard()}
+	broke.Inventory = map[string]Inventory{
+		consumable.KeyApple: {Cost: 10, Goods: apples(10), Consumable: consumable.NewApple()},
+	}
+	orchard := broke.Producers[0]
+
+	s.AddAgent(&broke)
+	r := s.Step()
+	assert.Empty(t, r.Bankrupt)
+
+	// Rich enough to found a firm
+	rich := NewAgent(s.Market(), s.LaborMarket())
+	rich.Name = "rich"
+	rich.SeeksWage = true
+	rich.Cash = 2000
+	s.AddAgent(&rich)
+
+	r = s.Step()
+	assert.Equal(t, []string{"broke"}, r.Bankrupt)
+	assert.Equal(t, []string{"rich's orchard"}, r.Founded)
+	assert.NoError(t, s.CheckLedger())
+
+	// The founder bought the bankrupt orchard
+	// and staked the firm with the rest of half
+	// its cash
+	agents := s.Agents()
+	if assert.Len(t, agents, 2) {
+		firm := agents[1]
+		assert.Equal(t, []producer.Producer{orchard}, firm.Producers)
+		assert.Equal(t, 1000-orchard.Value(), firm.Balance())
+	}
+	assert.Equal(t, 1000.0, rich.Balance())
+	assert.Equal(t, orchard.Value(), broke.Balance())
+	assert.Empty(t, broke.Producers)
+
+	// With no owner and no debts what the orchard
+	// fetched is shared out by the next tick, between
+	// the two agents in it before rich founds another
+	value := orchard.Value()
+	s.Step()
+	assert.Equal(t, 0.0, broke.Balance())
+	agents = s.Agents()
+	assert.InDelta(t, value, agents[0].Report.Divested+agents[1].Report.Divested, 1e-9)
+	assert.NoError(t, s.CheckLedger())
+
+	// Its apples were put up at their value
+	if inv := <-s.Market().ReadLowest(consumable.KeyApple); assert.Equal(t, 10, inv.Goods.Count()) {
+		assert.Equal(t, "broke", inv.Originator)
+		assert.Equal(t, consumable.NewApple().Value(), inv.Price)
+	}
+}
@@ -0,0 +1,392 @@
+package lib
+
+import (
+	"eco/lib/producer"
+	"math"
+	"sort"
+)
+
+// Strategy decides what an Agent does each tick. The Agent
+// carries the decisions out: it places the orders, runs the
+// production cycles, lists the inventory and hires the labor.
+type Strategy interface {
+	// Bids returns the orders to place this tick given the
+	// cash the agent holds. From, Side and
+	// FulfillmentChannel are filled in by the Agent.
+	Bids(a *Agent, cash float64) []Order
+
+	// Price returns the asking price per unit
+	// for inventory sent to market.
+	Price(a *Agent, inv Inventory) float64
+
+	// Markup returns the markup to price key at from now
+	// on, given how many units sold since the last tick
+	// and how many are still listed.
+	Markup(a *Agent, key string, sold int, unsold int) float64
+
+	// Assign returns which of contracts work each of the
+	// agent's producers this tick, in the order of
+	// a.Producers. Each contract works one cycle of at
+	// most one producer.
+	Assign(a *Agent, contracts []LaborContract) [][]LaborContract
+
+	// Hire returns the job offers to post this tick, given
+	// the labor on offer and the wages it is asking.
+	Hire(a *Agent, labor []LaborContract) []JobOffer
+
+	// Layoffs returns the LaborContracts to end this tick.
+	Layoffs(a *Agent) []LaborContract
+
+	// Save returns what the agent wants on deposit at its
+	// bank this tick. Its cash makes up the difference.
+	Save(a *Agent) float64
+
+	// Borrow returns how much to borrow this tick.
+	Borrow(a *Agent) float64
+
+	// Invest returns the keys of the producers
+	// to buy this tick.
+	Invest(a *Agent) []string
+
+	// Divest returns the producers to sell this tick.
+	Divest(a *Agent) []producer.Producer
+}
+
+// DefaultStrategy is the Strategy of an Agent that sets
+// none. Agents without producers top up their Demands and
+// save the rest. Firms price at markups they learn, staff
+// their most profitable producers, and borrow and invest to
+// keep them running.
+type DefaultStrategy struct{}
+
+// Bids tops up every Demand at its price, and bids at
+// market for the inputs the producers lack with what the
+// payroll leaves of cash.
+func (DefaultStrategy) Bids(a *Agent, cash float64) []Order {
+	orders := []Order{}
+	bids := map[string]int{}
+	for _, d := range a.Demands {
+		// A satisfied demand still bids for
+		// nothing, cancelling any resting bid
+		bids[d.Consumable.Key()] = len(orders)
+		orders = append(orders, Order{
+			Quantity:   a.Need(d),
+			Price:      d.Price,
+			Consumable: d.Consumable,
+			Cash:       cash,
+			Priority:   d.Priority,
+		})
+	}
+
+	// Inputs are bought with what the payroll leaves
+	inputCash := cash - payroll(a, a.LaborContracts)
+	if inputCash < 0 {
+		inputCash = 0
+	}
+
+	// A later bid for the same key would replace
+	// the first, so inputs that are also demanded
+	// are added to the demand's bid
+	needed := a.InputsNeeded()
+	for _, p := range a.Producers {
+		for _, in := range p.Inputs() {
+			key := in.Consumable.Key()
+			q := needed[key]
+			delete(needed, key)
+			if q < 1 {
+				continue
+			}
+			if i, ok := bids[key]; ok {
+				orders[i].Quantity += q
+				continue
+			}
+			bids[key] = len(orders)
+			orders = append(orders, Order{
+				Quantity:   q,
+				Consumable: in.Consumable,
+				Cash:       inputCash,
+			})
+		}
+	}
+	return orders
+}
+
+// Price asks cost plus the markup times the value of the good.
+func (DefaultStrategy) Price(a *Agent, inv Inventory) float64 {
+	price := inv.Cost / float64(inv.Goods.Count())
+	price += a.Markup(inv.Consumable.Key()) * inv.Consumable.Value()
+	return price
+}
+
+// Markup raises the markup by PriceRaise when everything
+// sold, and cuts it by PriceCut times the share left unsold.
+func (DefaultStrategy) Markup(a *Agent, key string, sold int, unsold int) float64 {
+	markup := a.Markup(key)
+	switch {
+	case sold+unsold == 0:
+		// Nothing to learn from
+		return markup
+	case unsold == 0:
+		// A markup priced at cost can still rise
+		return markup + math.Max(markup, 1)*a.PriceRaise
+	default:
+		share := float64(unsold) / float64(sold+unsold)
+		return markup * (1 - math.Min(a.PriceCut*share, 1))
+	}
+}
+
+// Assign staffs the producers with the best expected margin
+// first, each with as many of the workers most skilled at it
+// as it has room for. No worker is put on a producer it is
+// expected to work at a loss at its wage.
+func (DefaultStrategy) Assign(a *Agent, contracts []LaborContract) [][]LaborContract {
+	staffed := make([][]LaborContract, len(a.Producers))
+	contracts = append([]LaborContract{}, contracts...)
+	for _, i := range byMargin(a) {
+		p := a.Producers[i]
+
+		// The most skilled at it first
+		sort.SliceStable(contracts, func(x, y int) bool {
+			return contracts[x].Agent.Skill(p.Key()) > contracts[y].Agent.Skill(p.Key())
+		})
+		left := []LaborContract{}
+		for _, l := range contracts {
+			full := p.Workers() > 0 && len(staffed[i]) >= p.Workers()
+			if full || loses(a, p, l) {
+				left = append(left, l)
+				continue
+			}
+			staffed[i] = append(staffed[i], l)
+		}
+		contracts = left
+	}
+	return staffed
+}
+
+// Hire offers one job a tick, at the wage of the best
+// producer with room for another worker, when the agent
+// can pay for a cycle of it.
+func (DefaultStrategy) Hire(a *Agent, labor []LaborContract) []JobOffer {
+	if len(labor) < 1 || len(a.Producers) < 1 {
+		return nil
+	}
+
+	p := vacancy(a)
+	if p == nil {
+		return nil
+	}
+	wage := p.Wage() * float64(p.Rate())
+	if wage+p.Cost()*float64(p.Rate()) > a.Balance() {
+		return nil
+	}
+	return []JobOffer{{Wage: wage, Key: p.Key()}}
+}
+
+// Layoffs lets go of the workers no producer has paying work for,
+// then of the best paid while the payroll cannot be met, or
+// of one worker when more than three ticks' output is unsold.
+func (DefaultStrategy) Layoffs(a *Agent) []LaborContract {
+	if len(a.LaborContracts) < 1 {
+		return nil
+	}
+
+	output := 0
+	assigned := map[*Agent]bool{}
+	for i, staff := range a.strategy().Assign(a, a.LaborContracts) {
+		output += a.Producers[i].Rate() * len(staff)
+		for _, l := range staff {
+			assigned[l.Agent] = true
+		}
+	}
+
+	// Those no producer has work for go first
+	fired := []LaborContract{}
+	contracts := []LaborContract{}
+	for _, l := range a.LaborContracts {
+		if assigned[l.Agent] {
+			contracts = append(contracts, l)
+		} else {
+			fired = append(fired, l)
+		}
+	}
+
+	// Then the best paid
+	sort.SliceStable(contracts, func(i, j int) bool {
+		return contracts[i].Wage > contracts[j].Wage
+	})
+	cash := a.Balance()
+	for len(contracts) > 0 && payroll(a, contracts) > cash {
+		fired = append(fired, contracts[0])
+		contracts = contracts[1:]
+	}
+
+	if len(fired) == 0 && len(contracts) > 0 && a.Market.Listed(a.Name) > 3*output {
+		fired = append(fired, contracts[0])
+	}
+	return fired
+}
+
+// Save keeps on deposit whatever an agent without producers
+// would not spend topping up every Demand at its price.
+// Firms save nothing.
+func (DefaultStrategy) Save(a *Agent) float64 {
+	if len(a.Producers) > 0 {
+		return 0
+	}
+
+	spend := 0.0
+	for _, d := range a.Demands {
+		spend += float64(a.Need(d)) * d.Price
+	}
+	return math.Max(0, a.Available()+a.Bank.Statement(a.Name).Deposit-spend)
+}
+
+// Borrow borrows what the payroll, or a first hire, needs
+// beyond the cash the agent has.
+func (DefaultStrategy) Borrow(a *Agent) float64 {
+	if len(a.Producers) < 1 {
+		return 0
+	}
+
+	needed := payroll(a, a.LaborContracts)
+	if len(a.LaborContracts) < 1 {
+		p := a.Producers[0]
+		needed = (p.Wage() + p.Cost()) * float64(p.Rate())
+	}
+	return math.Max(0, needed-a.Available())
+}
+
+// Invest spends what is left over three ticks' payroll on
+// replacing the producers once all are worn out, and Reinvest
+// of it on another of the most profitable when everything
+// sold and none has room for another worker.
+func (DefaultStrategy) Invest(a *Agent) []string {
+	if len(a.Producers) < 1 {
+		return nil
+	}
+
+	key := a.Producers[0].Key()
+	if best := byMargin(a); len(best) > 0 {
+		key = a.Producers[best[0]].Key()
+	}
+	price, ok := a.Capital.Quote(key)
+	if !ok {
+		return nil
+	}
+	retained := a.Available() - 3*payroll(a, a.LaborContracts)
+
+	replace := true
+	for _, p := range a.Producers {
+		if !worn(p) {
+			replace = false
+		}
+	}
+	if replace {
+		if retained < price {
+			return nil
+		}
+		return []string{key}
+	}
+
+	if a.Reinvest <= 0 || retained*a.Reinvest < price {
+		return nil
+	}
+	if a.Market.Listed(a.Name) > 0 || vacancy(a) != nil {
+		return nil
+	}
+	return []string{key}
+}
+
+// Divest sells the most worn producer but the last
+// when the agent cannot pay for a cycle.
+func (DefaultStrategy) Divest(a *Agent) []producer.Producer {
+	if len(a.Producers) < 2 || a.Solvent() {
+		return nil
+	}
+
+	most := a.Producers[0]
+	for _, p := range a.Producers[1:] {
+		if p.Condition() < most.Condition() {
+			most = p
+		}
+	}
+	return []producer.Producer{most}
+}
+
+// payroll returns what working contracts as the strategy
+// assigns them costs for a tick, wages and all.
+func payroll(a *Agent, contracts []LaborContract) float64 {
+	total := 0.0
+	for i, staff := range a.strategy().Assign(a, contracts) {
+		p := a.Producers[i]
+		for _, l := range staff {
+			total += l.Wage + p.Cost()*float64(p.Rate())
+		}
+	}
+	return total
+}
+
+// margin returns what the agent expects to make on a unit
+// of p paying wage for it: the price its output last sold
+// at less its cost, the wage and its share of the inputs at
+// what they last sold at. Until the output sells, it is the
+// agent's markup.
+func margin(a *Agent, p producer.Producer, wage float64) float64 {
+	c := p.Type()
+	price, ok := a.Market.LastPrice(c.Key())
+	if !ok {
+		return a.Markup(c.Key()) * c.Value()
+	}
+
+	inputs := 0.0
+	for _, in := range p.Inputs() {
+		key := in.Consumable.Key()
+		cost, ok := a.Market.LastPrice(key)
+		if !ok {
+			cost = a.Paid(key)
+		}
+		inputs += float64(in.Quantity) * cost
+	}
+	return price - p.Cost() - wage - inputs/float64(p.Rate())
+}
+
+// loses reports whether l is expected to lose money on every
+// unit it makes working p, at the wage of its contract. Until
+// the output sells there is nothing to go on, and it is not.
+func loses(a *Agent, p producer.Producer, l LaborContract) bool {
+	if _, ok := a.Market.LastPrice(p.Type().Key()); !ok {
+		return false
+	}
+	return margin(a, p, l.Wage/float64(units(p, l.Agent))) <= 0
+}
+
+// byMargin returns the index of every producer that can run
+// this tick, the best expected margin at its posted wage first.
+func byMargin(a *Agent) []int {
+	order := []int{}
+	margins := make([]float64, len(a.Producers))
+	for i, p := range a.Producers {
+		if a.unmaintained[p] {
+			continue
+		}
+		order = append(order, i)
+		margins[i] = margin(a, p, p.Wage())
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return margins[order[i]] > margins[order[j]]
+	})
+	return order
+}
+
+// vacancy returns the producer with the best expected margin
+// that has room for another worker, or nil when none has.
+func vacancy(a *Agent) producer.Producer {
+	staffed := a.strategy().Assign(a, a.LaborContracts)
+	for _, i := range byMargin(a) {
+		p := a.Producers[i]
+		if p.Workers() < 1 || len(staffed[i]) < p.Workers() {
+			return p
+		}
+	}
+	return nil
+}
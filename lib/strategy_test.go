@@ -0,0 +1,128 @@
+package lib
+
+import (
+	"eco/lib/consumable"
+	"eco/lib/producer"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// lowballStrategy bids a fixed price for ten apples
+// and never hires.
+type lowballStrategy struct {
+	DefaultStrategy
+	price float64
+}
+
+func (s lowballStrategy) Bids(a *Agent, cash float64) []Order {
+	return []Order{{
+		Quantity:   10,
+		Price:      s.price,
+		Consumable: consumable.NewApple(),
+		Cash:       cash,
+	}}
+}
+
+func (s lowballStrategy) Hire(a *Agent, labor []LaborContract) []JobOffer {
+	return nil
+}
+
+func TestStrategy(t *testing.T) {
+	s := NewSimulation(Config{Seed: 1, Deterministic: true})
+	defer s.Stop()
+
+	worker := NewAgent(s.Market(), s.LaborMarket())
+	worker.Name = "worker"
+	worker.SeeksWage = true
+
+	a := NewAgent(s.Market(), s.LaborMarket())
+	a.Name = "lowball"
+	a.Cash = 100
+	a.Producers = []producer.Producer{producer.NewOrchard()}
+	a.Inventory = map[string]Inventory{}
+	a.Strategy = lowballStrategy{price: 0.5}
+
+	s.AddAgent(&worker, &a)
+	s.Step()
+	s.Step()
+
+	bids := s.Market().Bids(consumable.KeyApple)
+	if assert.Len(t, bids, 1) {
+		assert.Equal(t, "lowball", bids[0].From)
+		assert.Equal(t, 0.5, bids[0].Price)
+	}
+	assert.Empty(t, a.LaborContracts)
+	assert.False(t, worker.IsEmployed)
+}
+
+func TestAssignByMargin(t *testing.T) {
+	catalog, err := ReadCatalog(strings.NewReader(`{
+		"consumables": [
+			{"key": "apple", "value": 0.25, "scale": 5, "shelfLife": 20},
+			{"key": "pear", "value": 0.5, "scale": 2, "shelfLife": 4}
+		],
+		"producers": [
+			{"key": "orchard", "output": "apple", "rate": 10, "cost": 1, "wage": 1, "value": 10, "workers": 2},
+			{"key": "grove", "output": "pear", "rate": 3, "cost": 1, "wage": 1, "value": 10, "workers": 1}
+		]
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	m := NewMarket()
+	l := NewLaborMarket()
+	a := NewAgent(&m, &l)
+	a.Name = "firm"
+	a.Cash = 1000
+	a.Greed = 1
+	a.Inventory = map[string]Inventory{}
+	for _, key := range []string{"orchard", "grove"} {
+		p, _ := catalog.Producers.New(key)
+		a.Producers = append(a.Producers, p)
+	}
+	a.Start()
+	defer a.Quit()
+
+	for i := 0; i < 3; i++ {
+		worker := NewAgent(&m, &l)
+		worker.Start()
+		defer worker.Quit()
+		a.LaborContracts = append(a.LaborContracts, LaborContract{Agent: &worker, Wage: 10})
+	}
+
+	// Pears make more over cost, so the grove
+	// is staffed first and the orchard takes
+	// the rest
+	staffed := DefaultStrategy{}.Assign(&a, a.LaborContracts)
+	assert.Equal(t, a.LaborContracts[:1], staffed[1])
+	assert.Equal(t, a.LaborContracts[1:], staffed[0])
+	assert.Nil(t, vacancy(&a))
+
+	// Labor moves to apples once they make more
+	a.markup["apple"] = 4
+	staffed = DefaultStrategy{}.Assign(&a, a.LaborContracts)
+	assert.Equal(t, a.LaborContracts[:2], staffed[0])
+	assert.Equal(t, a.LaborContracts[2:], staffed[1])
+
+	// Every contract works one cycle
+	a.Produce(a.Cash)
+	assert.Equal(t, 3, a.Report.ProductCylces)
+	assert.Equal(t, 20, a.Inventory["apple"].Goods.Count())
+	assert.Equal(t, 3, a.Inventory["pear"].Goods.Count())
+
+	// Without the grove the orchard has no
+	// work for the third, who is let go
+	a.Producers = a.Producers[:1]
+	assert.Equal(t, a.LaborContracts[2:], DefaultStrategy{}.Layoffs(&a))
+
+	// Nobody works at a loss, at the wage of their contract
+	m.prices["apple"] = 1
+	assert.Empty(t, DefaultStrategy{}.Assign(&a, a.LaborContracts)[0])
+	assert.Equal(t, a.LaborContracts, DefaultStrategy{}.Layoffs(&a))
+
+	m.prices["apple"] = 2.5
+	a.LaborContracts[0].Wage = 20
+	assert.Equal(t, a.LaborContracts[1:], DefaultStrategy{}.Assign(&a, a.LaborContracts)[0])
+}
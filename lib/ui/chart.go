@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"eco/lib"
+	"fmt"
+	"github.com/wcharczuk/go-chart"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Chart collects market reports tick by tick and renders
+// them with go-chart. Unlike Graph it needs no display.
+type Chart struct {
+	tick int
+
+	ticks    []float64
+	sold     []float64
+	avgPrice []float64
+}
+
+func NewChart() *Chart {
+	return &Chart{
+		ticks:    []float64{0},
+		sold:     []float64{0},
+		avgPrice: []float64{0},
+	}
+}
+
+// Update appends the report as the next tick.
+func (c *Chart) Update(report lib.MarketReport) {
+	c.tick++
+
+	avg := 0.0
+	if report.ProductSold > 0 {
+		avg = report.TotalCashFlow / float64(report.ProductSold)
+	}
+
+	c.ticks = append(c.ticks, float64(c.tick))
+	c.sold = append(c.sold, float64(report.ProductSold))
+	c.avgPrice = append(c.avgPrice, avg)
+}
+
+func (c *Chart) FormatTickFunc() func(interface{}) string {
+	return func(tick interface{}) string {
+		return fmt.Sprintf("%.0f", tick)
+	}
+}
+
+func (c *Chart) chart() chart.Chart {
+	graph := chart.Chart{
+		Series: []chart.Series{
+			chart.ContinuousSeries{
+				Name:            "Sold",
+				XValueFormatter: chart.ValueFormatter(c.FormatTickFunc()),
+				XValues:         c.ticks,
+				YValues:         c.sold,
+			},
+			chart.ContinuousSeries{
+				Name:            "Avg Price",
+				YAxis:           chart.YAxisSecondary,
+				XValueFormatter: chart.ValueFormatter(c.FormatTickFunc()),
+				XValues:         c.ticks,
+				YValues:         c.avgPrice,
+			},
+		},
+	}
+	graph.Elements = []chart.Renderable{chart.Legend(&graph)}
+	return graph
+}
+
+// Render writes the chart to w as a PNG or an SVG.
+func (c *Chart) Render(format string, w io.Writer) error {
+	switch strings.ToLower(format) {
+	case "png":
+		return c.chart().Render(chart.PNG, w)
+	case "svg":
+		return c.chart().Render(chart.SVG, w)
+	}
+	return fmt.Errorf("ui: unknown chart format %q", format)
+}
+
+// Save renders the chart to path, in the format
+// given by its extension.
+func (c *Chart) Save(path string) error {
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if format != "png" && format != "svg" {
+		return fmt.Errorf("ui: cannot tell the chart format of %q, use .png or .svg", path)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Render(format, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -1,3 +1,6 @@
+//go:build !headless
+// +build !headless
+
 package ui
 
 import (
@@ -6,7 +9,6 @@ import (
 	"fyne.io/fyne"
 	"fyne.io/fyne/app"
 	"fyne.io/fyne/canvas"
-	"github.com/wcharczuk/go-chart"
 	"image/color"
 )
 
@@ -29,15 +31,18 @@ type GraphLine struct {
 	Next *canvas.Line
 }
 
+// HeadlessOnly reports that this build leaves out fyne
+// and cannot show a Graph.
+const HeadlessOnly = false
+
+// Graph shows a Chart in a window. It needs a display;
+// headless runs should use a Chart on its own.
 type Graph struct {
-	product [][]int
-	chart   chart.Chart
+	chart *Chart
 
 	report     lib.MarketReport
 	lastReport *lib.MarketReport
 
-	tick int
-
 	app    fyne.App
 	window fyne.Window
 	canvas *fyne.Container
@@ -49,7 +54,7 @@ func NewGraph() *Graph {
 	w.Resize(fyne.Size{400, 300})
 
 	g := &Graph{
-		chart:  chart.Chart{},
+		chart:  NewChart(),
 		app:    a,
 		window: w,
 	}
@@ -65,37 +70,20 @@ func (g *Graph) Start() {
 	g.window.ShowAndRun()
 }
 
-func (g *Graph) FormatTickFunc() func(interface{}) string {
-	return func(tick interface{}) string {
-		return fmt.Sprintf("%.0f", tick)
-	}
+// Chart returns the Chart the Graph is showing.
+func (g *Graph) Chart() *Chart {
+	return g.chart
 }
 
 func (g *Graph) render(new *lib.MarketReport) *fyne.Container {
 	if new == nil {
-		g.chart.Series = []chart.Series{
-			chart.ContinuousSeries{
-				XValueFormatter: chart.ValueFormatter(g.FormatTickFunc()),
-				XValues:         []float64{0},
-				YValues:         []float64{0},
-			},
-		}
-		g.product = [][]int{{0, 0}}
 		return g.canvas
 	}
 
-	newValue := []int{g.tick, new.ProductSold}
-	g.product = append(g.product, newValue)
-
-	switch v := g.chart.Series[0].(type) {
-	case chart.ContinuousSeries:
-		v.XValues = append(v.XValues, float64(newValue[0]))
-		v.YValues = append(v.YValues, float64(newValue[1]))
-		g.chart.Series[0] = v
-	}
+	g.chart.Update(*new)
 
 	buffer := bytes.NewBuffer([]byte{})
-	err := g.chart.Render(chart.PNG, buffer)
+	err := g.chart.Render("png", buffer)
 	if err != nil {
 		panic(err)
 
@@ -109,8 +97,6 @@ func (g *Graph) render(new *lib.MarketReport) *fyne.Container {
 }
 
 func (g *Graph) Update(report lib.MarketReport) {
-	g.tick++
-
 	g.lastReport = &g.report
 	g.report = report
 
@@ -0,0 +1,34 @@
+//go:build headless
+// +build headless
+
+package ui
+
+import (
+	"eco/lib"
+)
+
+// HeadlessOnly reports that this build leaves out fyne
+// and cannot show a Graph.
+const HeadlessOnly = true
+
+// Graph is not available in headless builds,
+// which leave out fyne entirely.
+type Graph struct {
+	chart *Chart
+}
+
+// NewGraph returns nil, there is no window to show.
+func NewGraph() *Graph {
+	return nil
+}
+
+func (g *Graph) Start() {
+}
+
+// Chart returns the Chart the Graph is showing.
+func (g *Graph) Chart() *Chart {
+	return g.chart
+}
+
+func (g *Graph) Update(report lib.MarketReport) {
+}
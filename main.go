@@ -3,17 +3,20 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"eco/lib"
 	"eco/lib/consumable"
-	"eco/lib/producer"
 	"eco/lib/ui"
 	"flag"
+	"fmt"
 	"github.com/Pallinder/go-randomdata"
 	"github.com/olekukonko/tablewriter"
+	"io"
+	"math"
 	"math/rand"
 	"os"
-	//"sort"
-	"sync"
+	"os/signal"
+	"path/filepath"
 	"time"
 )
 
@@ -25,10 +28,28 @@ var step bool
 var agentCount int
 var supplierCount int
 var suppressTables bool
+var seed int64
+var deterministic bool
+var ticks int
+var headless bool
+var chartPath string
+var exportDir string
+var exportFormat string
+var audit bool
+var catalogPath string
+var settlementTimeout int
+var priceRaise float64
+var priceCut float64
+var policy lib.Policy
+var banking lib.BankPolicy
+var withBank bool
+var bankruptAfter int
+var entryWealth float64
+var reinvest float64
+var skillSpread float64
+var learning float64
 
 func main() {
-	rand.Seed(time.Now().Unix())
-
 	flag.IntVar(&interval, "i", 100, "tick interval in ms")
 	flag.IntVar(&timeout, "t", 0, "sim timeout")
 	flag.IntVar(&agentCount, "ac", 10, "count of agents")
@@ -37,148 +58,332 @@ func main() {
 	flag.BoolVar(&debug, "d", false, "print debug logs")
 	flag.BoolVar(&step, "step", false, "step through ticks")
 	flag.BoolVar(&suppressTables, "shh", false, "suppressTables")
+	flag.Int64Var(&seed, "seed", 0, "random seed (defaults to the current time)")
+	flag.BoolVar(&deterministic, "det", false, "process agents one at a time in a fixed order")
+	flag.IntVar(&ticks, "n", 0, "number of ticks to run (0 runs until the timeout)")
+	flag.BoolVar(&headless, "headless", false, "run without the graph window")
+	flag.StringVar(&chartPath, "chart", "", "write the market chart to this .png or .svg file when the run ends")
+	flag.StringVar(&exportDir, "export", "", "write per-tick agent and market records to this directory")
+	flag.StringVar(&exportFormat, "format", "csv", "export format, csv or jsonl")
+	flag.BoolVar(&audit, "audit", false, "check the ledger after every tick (exact with -det)")
+	flag.IntVar(&settlementTimeout, "st", 0, "settlement timeout in ms (0 waits forever)")
+	flag.Float64Var(&priceRaise, "raise", 0.05, "fraction suppliers raise their markup by when everything sold")
+	flag.Float64Var(&priceCut, "cut", 0.1, "fraction suppliers cut their markup by when nothing sold")
+	flag.Float64Var(&policy.IncomeTax, "income-tax", 0, "share of wages withheld as income tax")
+	flag.Float64Var(&policy.SalesTax, "sales-tax", 0, "share of every sale taken as sales tax")
+	flag.Float64Var(&policy.CorporateTax, "corporate-tax", 0, "share of supplier revenue taken as corporate tax")
+	flag.Float64Var(&policy.UnemploymentBenefit, "benefit", 0, "unemployment benefit paid each tick")
+	flag.Float64Var(&policy.BasicIncome, "ubi", 0, "basic income paid to every agent each tick")
+	flag.BoolVar(&withBank, "bank", false, "run a bank taking deposits and making loans")
+	flag.Float64Var(&banking.Capital, "bank-capital", 0, "cash the bank starts with")
+	flag.Float64Var(&banking.DepositRate, "deposit-rate", 0, "interest paid on deposits each tick")
+	flag.Float64Var(&banking.LoanRate, "loan-rate", 0, "interest charged on loans each tick")
+	flag.IntVar(&banking.Term, "loan-term", 10, "ticks a loan is repaid over")
+	flag.Float64Var(&banking.LoanToValue, "ltv", 0, "share of collateral the bank lends against")
+	flag.Float64Var(&banking.CreditPerRepayment, "credit", 0, "unsecured credit per loan repaid")
+	flag.IntVar(&banking.Grace, "grace", 2, "installments a borrower may miss before it defaults")
+	flag.Float64Var(&banking.ReserveRatio, "reserve-ratio", 0.1, "share of deposits the bank keeps in reserve")
+	flag.IntVar(&bankruptAfter, "bankrupt-after", 0, "ticks a supplier may be unable to pay for production before it is liquidated (0 never)")
+	flag.Float64Var(&entryWealth, "entry-wealth", 0, "cash a consumer needs to found a firm (0 founds none)")
+	flag.Float64Var(&reinvest, "reinvest", 0, "share of retained earnings suppliers invest in more producers")
+	flag.Float64Var(&skillSpread, "skill-spread", 0, "how far consumers' skills at each producer may start from one, below one")
+	flag.Float64Var(&learning, "learning", 0, "how fast consumers' skills grow with every cycle they work")
+	flag.StringVar(&catalogPath, "catalog", "", "load goods and producers from this JSON catalog (defaults to apples and orchards)")
 	flag.Parse()
 
 	lib.Debug = debug
 	lib.Verbose = verbose
 
-	m := lib.NewMarket()
-	l := lib.NewLaborMarket()
+	// A build without fyne has no window to show
+	if ui.HeadlessOnly {
+		headless = true
+	}
 
-	agents := []lib.Agent{}
-	agents = append(agents, GenerateConsumers(agentCount-supplierCount, &m, &l)...)
-	agents = append(agents, GenerateSuppliers(supplierCount, &m, &l)...)
+	if !withBank {
+		banking = lib.BankPolicy{}
+	}
 
-	// Start the market
-	go m.Start()
+	// Only a government or bank that is there is reported on
+	hasGovernment := policy != (lib.Policy{})
+	hasBank := banking != (lib.BankPolicy{})
 
-	// Startup agents
-	for i := range agents {
-		go agents[i].Start()
+	catalog := lib.DefaultCatalog()
+	if catalogPath != "" {
+		c, err := lib.LoadCatalog(catalogPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		catalog = c
 	}
 
-	// Send ticks to each agent
-	var timeoutChan <-chan time.Time
-	if timeout > 0 {
-		timeoutChan = time.After(time.Duration(timeout) * time.Second)
+	var graph *ui.Graph
+	if !headless {
+		graph = ui.NewGraph()
 	}
+	chart := ui.NewChart()
 
-	wg := sync.WaitGroup{}
+	exporters := []lib.Exporter{}
+	if exportDir != "" {
+		e, err := NewExporter(exportDir, exportFormat, hasGovernment, hasBank)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		exporters = append(exporters, e)
+	}
 
-	report := make(chan chan bool)
-	reports := make(chan []string)
+	var sim *lib.Simulation
+	sim = lib.NewSimulation(lib.Config{
+		Seed:              seed,
+		Deterministic:     deterministic,
+		Ticks:             ticks,
+		Interval:          time.Duration(interval) * time.Millisecond,
+		SettlementTimeout: time.Duration(settlementTimeout) * time.Millisecond,
+		Policy:            policy,
+		Banking:           banking,
+		BankruptAfter:     bankruptAfter,
+		EntryWealth:       entryWealth,
+		Catalog:           catalog,
+		Exporters:         exporters,
+		OnTick: func(report lib.TickReport) {
+			chart.Update(report.Market)
+			if graph != nil {
+				graph.Update(report.Market)
+			}
 
-	graph := ui.NewGraph()
+			if !suppressTables {
+				RenderTables(report, hasGovernment, hasBank)
+			}
 
-	// Collect agent reports and report them in a table
-	go func() {
-		records := [][]string{}
-		for {
-			select {
-			case done := <-report:
-				// Render Agents table
-				agentsTable := tablewriter.NewWriter(os.Stdout)
-				agentsTable.SetHeader([]string{"Name", "Greed", "Cash", "Consumables", "Market Sent", "Produced", "Revenue"})
-				agentsTable.AppendBulk(records)
-				records = [][]string{}
+			if audit {
+				if err := sim.CheckLedger(); err != nil {
+					fmt.Fprintf(os.Stderr, "tick %d: %v\n", report.Tick, err)
+				}
+			}
 
-				graph.Update(m.MarketReport())
+			if step {
+				input := bufio.NewScanner(os.Stdin)
+				input.Scan()
+			}
+		},
+	})
 
-				// Render Market table
-				returnedRecord := make(chan []string)
-				m.ReportChannel <- returnedRecord
-				r := <-returnedRecord
-				marketTable := tablewriter.NewWriter(os.Stdout)
-				marketTable.SetHeader([]string{"Sold", "Received", "Total Cash Flow", "Avg Price", "Stock"})
-				marketTable.Append(r)
+	r := sim.Rand()
+	randomdata.CustomRand(r)
 
-				if !suppressTables {
-					agentsTable.Render()
-					marketTable.Render()
-				}
+	m, l := sim.Market(), sim.LaborMarket()
+	sim.AddAgent(GenerateConsumers(r, catalog, agentCount-supplierCount, m, l)...)
+	sim.AddAgent(GenerateSuppliers(r, catalog, supplierCount, m, l)...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if timeout > 0 && !step {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
 
-				done <- true
-			case r := <-reports:
-				records = append(records, r)
+	run := func() {
+		if err := sim.Run(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		sim.Stop()
+
+		if chartPath != "" {
+			if err := chart.Save(chartPath); err != nil {
+				fmt.Fprintln(os.Stderr, err)
 			}
 		}
-	}()
-
-	go func() {
-		for {
-			select {
-			case <-timeoutChan:
-				if step {
-					continue
-				}
-				m.Quit()
-				return
-			default:
-				wg.Add(len(agents))
-				for i := range agents {
-					a := &agents[i]
-					go func() {
-						reports <- a.Actions()
-						wg.Done()
-					}()
-				}
-				wg.Wait()
+	}
 
-				resume := make(chan bool)
-				report <- resume
-				<-resume
+	if headless {
+		run()
+		return
+	}
 
-				if step {
-					input := bufio.NewScanner(os.Stdin)
-					input.Scan()
-				}
+	go run()
+	graph.Start()
+}
+
+// NewExporter creates agents and markets files in dir, and
+// government and bank files when there is a government or a
+// bank to report on, and returns an Exporter writing format
+// to them.
+func NewExporter(dir string, format string, government bool, bank bool) (lib.Exporter, error) {
+	if format != "csv" && format != "jsonl" {
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// What was created is closed again if the rest cannot be
+	created := []*os.File{}
+	create := func(name string, w *io.Writer) error {
+		f, err := os.Create(filepath.Join(dir, name+"."+format))
+		if err != nil {
+			for _, f := range created {
+				f.Close()
 			}
+			return err
 		}
-	}()
+		created = append(created, f)
+		*w = f
+		return nil
+	}
 
-	graph.Start()
+	w := lib.ExportWriters{}
+	if err := create("agents", &w.Agents); err != nil {
+		return nil, err
+	}
+	if err := create("markets", &w.Markets); err != nil {
+		return nil, err
+	}
+	if government {
+		if err := create("government", &w.Government); err != nil {
+			return nil, err
+		}
+	}
+	if bank {
+		if err := create("bank", &w.Bank); err != nil {
+			return nil, err
+		}
+	}
+
+	if format == "jsonl" {
+		return lib.NewJSONLExporter(w), nil
+	}
+	return lib.NewCSVExporter(w), nil
 }
 
-func NewRandomizedConsumer(m *lib.Market, l *lib.LaborMarket) lib.Agent {
+// RenderTables prints the agents and market tables for a
+// tick, and the government and bank tables when there is a
+// government or a bank to report on.
+func RenderTables(report lib.TickReport, government bool, bank bool) {
+	agentsTable := tablewriter.NewWriter(os.Stdout)
+	agentsTable.SetHeader([]string{"Name", "Greed", "Cash", "Consumables", "Market Sent", "Produced", "Revenue"})
+	agentsTable.AppendBulk(report.Agents)
+
+	marketTable := tablewriter.NewWriter(os.Stdout)
+	marketTable.SetHeader([]string{"Sold", "Received", "Total Cash Flow", "Avg Price", "Stock"})
+	marketTable.Append(report.MarketRecord)
+
+	g := report.Government
+	governmentTable := tablewriter.NewWriter(os.Stdout)
+	governmentTable.SetHeader([]string{"Treasury", "Income Tax", "Sales Tax", "Corporate Tax", "Benefits", "Basic Income", "Shortfall"})
+	governmentTable.Append([]string{
+		fmt.Sprintf("%.2f", g.Treasury),
+		fmt.Sprintf("%.2f", g.IncomeTax),
+		fmt.Sprintf("%.2f", g.SalesTax),
+		fmt.Sprintf("%.2f", g.CorporateTax),
+		fmt.Sprintf("%.2f", g.UnemploymentBenefits),
+		fmt.Sprintf("%.2f", g.BasicIncome),
+		fmt.Sprintf("%.2f", g.Shortfall),
+	})
+
+	b := report.Bank
+	bankTable := tablewriter.NewWriter(os.Stdout)
+	bankTable.SetHeader([]string{"Reserves", "Deposits", "Loans", "Lent", "Repaid", "Interest", "Written Off", "Defaults"})
+	bankTable.Append([]string{
+		fmt.Sprintf("%.2f", b.Reserves),
+		fmt.Sprintf("%.2f", b.Deposits),
+		fmt.Sprintf("%.2f", b.Loans),
+		fmt.Sprintf("%.2f", b.Lent),
+		fmt.Sprintf("%.2f", b.Repaid),
+		fmt.Sprintf("%.2f", b.InterestCharged),
+		fmt.Sprintf("%.2f", b.WrittenOff),
+		fmt.Sprintf("%d", b.Defaults),
+	})
+
+	agentsTable.Render()
+	marketTable.Render()
+	if government {
+		governmentTable.Render()
+	}
+	if bank {
+		bankTable.Render()
+	}
+
+	for _, name := range report.Bankrupt {
+		fmt.Printf("%s went bankrupt.\n", name)
+	}
+	for _, name := range report.Founded {
+		fmt.Printf("%s was founded.\n", name)
+	}
+	if c := report.Capital; c.Built+c.Resold+c.Scrapped > 0 {
+		fmt.Printf("%d producers built, %d resold and %d scrapped for %.2f.\n", c.Built, c.Resold, c.Scrapped, c.Invested)
+	}
+}
+
+// Every random draw below comes from r so that a seed
+// reproduces the same agents.
+
+// NewRandomizedConsumer demands every good in the catalog,
+// and starts with a skill at every producer when skills
+// are spread.
+func NewRandomizedConsumer(r *rand.Rand, catalog *lib.Catalog, m *lib.Market, l *lib.LaborMarket) *lib.Agent {
 	a := lib.NewAgent(m, l)
 	a.Name = randomdata.LastName()
 	a.SeeksWage = true
-	a.Cash = RandomCash()
-	a.Demands = []consumable.Demand{
-		{
-			Consumable: consumable.NewApple(),
-			Quantity:   rand.Intn(10000-200) + 200,
-		},
+	a.Cash = RandomCash(r)
+	a.ReservationWage = float64(r.Intn(60-30) + 30)
+	a.Learning = learning
+	if skillSpread > 0 {
+		for _, key := range catalog.Producers.Keys() {
+			a.Skills[key] = math.Max(.1, 1+skillSpread*(2*r.Float64()-1))
+		}
+	}
+	for _, key := range catalog.Goods.Keys() {
+		c, _ := catalog.Goods.New(key)
+		a.Demands = append(a.Demands, consumable.Demand{
+			Consumable: c,
+			Quantity:   r.Intn(10000-200) + 200,
+			Price:      RandomPrice(r),
+		})
 	}
-	return a
+	return &a
 }
 
-func NewRandomizedSupplier(m *lib.Market, l *lib.LaborMarket) lib.Agent {
+// NewRandomizedSupplier runs one producer picked from the catalog.
+func NewRandomizedSupplier(r *rand.Rand, catalog *lib.Catalog, m *lib.Market, l *lib.LaborMarket) *lib.Agent {
 	a := lib.NewAgent(m, l)
 	a.Name = randomdata.State(randomdata.Large)
-	a.Cash = RandomCash()
-	a.Greed = rand.Intn(200-20) + 20
-	a.Producers = append(a.Producers, producer.NewOrchard())
+	a.Cash = RandomCash(r)
+	a.Greed = r.Intn(200-20) + 20
+	a.PriceRaise = priceRaise
+	a.PriceCut = priceCut
+	a.Reinvest = reinvest
+	keys := catalog.Producers.Keys()
+	if len(keys) > 0 {
+		p, _ := catalog.Producers.New(keys[r.Intn(len(keys))])
+		a.Producers = append(a.Producers, p)
+	}
 	a.Inventory = map[string]lib.Inventory{}
-	return a
+	return &a
+}
+
+func RandomCash(r *rand.Rand) float64 {
+	return float64(r.Intn(2000-500) + 500)
 }
 
-func RandomCash() float64 {
-	return float64(rand.Intn(2000-500) + 500)
+func RandomPrice(r *rand.Rand) float64 {
+	return float64(r.Intn(60-10) + 10)
 }
 
-func GenerateConsumers(count int, m *lib.Market, l *lib.LaborMarket) []lib.Agent {
-	agents := []lib.Agent{}
+func GenerateConsumers(r *rand.Rand, catalog *lib.Catalog, count int, m *lib.Market, l *lib.LaborMarket) []*lib.Agent {
+	agents := []*lib.Agent{}
 	for i := 0; i < count; i++ {
-		agents = append(agents, NewRandomizedConsumer(m, l))
+		agents = append(agents, NewRandomizedConsumer(r, catalog, m, l))
 	}
 	return agents
 }
 
-func GenerateSuppliers(count int, m *lib.Market, l *lib.LaborMarket) []lib.Agent {
-	agents := []lib.Agent{}
+func GenerateSuppliers(r *rand.Rand, catalog *lib.Catalog, count int, m *lib.Market, l *lib.LaborMarket) []*lib.Agent {
+	agents := []*lib.Agent{}
 	for i := 0; i < count; i++ {
-		agents = append(agents, NewRandomizedSupplier(m, l))
+		agents = append(agents, NewRandomizedSupplier(r, catalog, m, l))
 	}
 	return agents
 }